@@ -3,18 +3,24 @@ package main
 import (
 	"github.com/dinosaursrarr/hackney-bindicator/client"
 	"github.com/dinosaursrarr/hackney-bindicator/handler"
+	"github.com/dinosaursrarr/hackney-bindicator/mqtt"
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
 
+	"context"
 	"embed"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	_ "time/tzdata"
 
 	"github.com/gorilla/mux"
-	"github.com/hashicorp/golang-lru/v2/expirable"
+	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/jonboulle/clockwork"
 )
 
@@ -31,26 +37,237 @@ func main() {
 
 	}
 
-	// Default expiry time of 15 mins, up to 4k cached entries
-	cache := expirable.NewLRU[string, interface{}](4096, nil, time.Minute*15)
-
 	httpClient := http.Client{}
 	clock := clockwork.NewRealClock()
+
+	// Properties rarely change their bins, but collection schedules can
+	// change from one day to the next.
+	cache, saveCache := newCache(clock)
+	go func() {
+		for range clock.NewTicker(time.Minute).Chan() {
+			saveCache()
+		}
+	}()
+	ttls := client.TTLs{
+		Addresses:   time.Hour * 24,
+		BinIds:      time.Hour * 24,
+		BinTypes:    time.Hour * 24 * 7,
+		WorkflowIds: time.Hour * 24,
+		Schedules:   time.Hour,
+		Responses:   time.Minute * 15,
+	}
+	// Last good copy of everything, in case the Council's API goes down
+	staleCache, _ := lru.New[string, interface{}](4096)
+	// Bins that no classification rule matched, to write rules for
+	unknownBinTypes, _ := client.NewUnknownBinTypes(256, clock)
 	apiHost, _ := url.Parse("https://waste-api-hackney-live.ieg4.net/f806d91c-e133-43a6-ba9a-c0ae4f4cccf6")
 	binsClient := client.BinsClient{
-		HttpClient: httpClient,
-		Clock:      clock,
-		ApiHost:    apiHost,
-		Cache:      cache,
+		HttpClient:   httpClient,
+		Clock:        clock,
+		ApiHost:      apiHost,
+		Cache:        cache,
+		CacheTTLs:    ttls,
+		StaleCache:   staleCache,
+		MaxStaleness: time.Hour * 24 * 7,
+		// Answer straight away once entries expire, and refresh them after.
+		StaleWhileRevalidate: time.Hour * 24,
+		Timeout:              time.Second * 10,
+		Retry: client.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond * 250,
+			MaxBackoff:     time.Second * 2,
+			Jitter:         0.5,
+		},
+		Breaker: &client.CircuitBreaker{
+			Threshold: 5,
+			Cooldown:  time.Second * 30,
+			Clock:     clock,
+		},
+		Coalescer:       &client.Coalescer{},
+		Classifier:      newClassifier(),
+		UnknownBinTypes: unknownBinTypes,
 	}
 
+	// Set LEGACY_DATES for clients that expect dates as timestamps.
+	legacyDates := os.Getenv("LEGACY_DATES") != ""
 	collectionHandler := handler.CollectionHandler{
-		Client: binsClient,
-		Cache:  cache,
+		Client:      binsClient,
+		Cache:       cache,
+		CacheTTLs:   ttls,
+		LegacyDates: legacyDates,
+	}
+	calendarHandler := handler.CalendarHandler{
+		Client:    binsClient,
+		Cache:     cache,
+		CacheTTLs: ttls,
+	}
+	scheduleHandler := handler.ScheduleHandler{
+		Client:      binsClient,
+		Cache:       cache,
+		CacheTTLs:   ttls,
+		LegacyDates: legacyDates,
 	}
 	addressHandler := handler.AddressHandler{
-		Client: binsClient,
-		Cache:  cache,
+		Client:    binsClient,
+		Cache:     cache,
+		CacheTTLs: ttls,
+	}
+	// Nobody needs to subscribe more than a few times an hour.
+	subscribeLimiter := &handler.RateLimiter{Burst: 5, Every: time.Minute * 10, Clock: clock}
+	if os.Getenv("FLY_APP_NAME") != "" {
+		// Fly's proxy says who is really calling.
+		subscribeLimiter.ClientIpHeader = "Fly-Client-IP"
+	}
+	reminders, stopReminders := context.WithCancel(context.Background())
+	// Webhooks are only offered when there is somewhere to keep them, so
+	// that they aren't lost whenever the service restarts.
+	var subscriptionHandler *handler.SubscriptionHandler
+	if path := os.Getenv("SUBSCRIPTIONS_FILE"); path != "" {
+		webhooks, err := reminder.NewStore[reminder.Webhook](path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		webhooks.MaxItems = 10000
+		webhooks.MaxPerProperty = 20
+		webhooks.PropertyOf = func(hook reminder.Webhook) string { return hook.PropertyId }
+		subscriptionHandler = &handler.SubscriptionHandler{
+			Client:   binsClient,
+			Webhooks: webhooks,
+		}
+		webhookSender := reminder.WebhookSender{
+			Store:       webhooks,
+			Lookup:      collectionOn(&collectionHandler),
+			HttpClient:  reminder.NewPublicHttpClient(time.Second * 10),
+			Clock:       clock,
+			MaxAttempts: 5,
+			Backoff:     time.Minute,
+		}
+		// The evening before collections, when bins need putting out.
+		go reminder.Scheduler{Clock: clock, Hour: 18}.Run(reminders, webhookSender.Send)
+	}
+	// Like webhooks, push notifications are only offered when they can be kept.
+	var pushSubscriptionHandler *handler.PushSubscriptionHandler
+	if path := os.Getenv("PUSH_SUBSCRIPTIONS_FILE"); path != "" {
+		pushes, err := reminder.NewStore[reminder.PushSubscription](path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pushes.MaxItems = 10000
+		pushes.MaxPerProperty = 20
+		pushes.PropertyOf = func(sub reminder.PushSubscription) string { return sub.PropertyId }
+		pushSubscriptionHandler = &handler.PushSubscriptionHandler{
+			Client: binsClient,
+			Pushes: pushes,
+		}
+		pushNotifier := reminder.PushNotifier{
+			Store:       pushes,
+			Lookup:      collectionOn(&collectionHandler),
+			HttpClient:  reminder.NewPublicHttpClient(time.Second * 10),
+			Clock:       clock,
+			MaxAttempts: 5,
+			Backoff:     time.Minute,
+		}
+		go pushNotifier.Run(reminders)
+	}
+	// Browsers can only be sent notifications when there is a VAPID key, and
+	// somewhere to keep subscriptions, like webhooks.
+	var webPushHandler *handler.WebPushSubscriptionHandler
+	webPushesPath := os.Getenv("WEBPUSH_SUBSCRIPTIONS_FILE")
+	if vapidKey := os.Getenv("VAPID_PRIVATE_KEY"); vapidKey != "" && webPushesPath != "" {
+		key, err := reminder.ParseVAPIDKey(vapidKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		subject := os.Getenv("VAPID_SUBJECT")
+		if subject == "" {
+			log.Fatal("VAPID_SUBJECT must be set to send web push notifications")
+		}
+		vapid := reminder.VAPID{Key: key, Subject: subject}
+		webPushes, err := reminder.NewStore[reminder.WebPushSubscription](webPushesPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		webPushes.MaxItems = 10000
+		webPushes.MaxPerProperty = 20
+		webPushes.PropertyOf = func(sub reminder.WebPushSubscription) string { return sub.PropertyId }
+		webPushHandler = &handler.WebPushSubscriptionHandler{
+			Client:        binsClient,
+			Subscriptions: webPushes,
+			VAPID:         vapid,
+		}
+		webPushSender := &reminder.WebPushSender{
+			Store:       webPushes,
+			Lookup:      collectionOn(&collectionHandler),
+			HttpClient:  reminder.NewPublicHttpClient(time.Second * 10),
+			VAPID:       vapid,
+			Clock:       clock,
+			MaxAttempts: 5,
+			Backoff:     time.Minute,
+		}
+		go reminder.Scheduler{Clock: clock, Hour: 18}.Run(reminders, webPushSender.Send)
+	}
+	// Emails are only sent when there is an SMTP relay to send them through,
+	// and somewhere to keep subscriptions, like webhooks.
+	var emailHandler *handler.EmailSubscriptionHandler
+	emailsPath := os.Getenv("EMAIL_SUBSCRIPTIONS_FILE")
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" && emailsPath != "" {
+		key := os.Getenv("EMAIL_KEY")
+		baseUrl := os.Getenv("BASE_URL")
+		if key == "" || baseUrl == "" {
+			log.Fatal("EMAIL_KEY and BASE_URL must be set to send emails")
+		}
+		emails, err := reminder.NewStore[reminder.EmailSubscription](emailsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		emails.MaxItems = 10000
+		emails.MaxPerProperty = 20
+		emails.PropertyOf = func(sub reminder.EmailSubscription) string { return sub.PropertyId }
+		emailReminders := &reminder.EmailReminders{
+			Store:  emails,
+			Lookup: collectionOn(&collectionHandler),
+			Mailer: reminder.SMTPMailer{
+				Addr:     addr,
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+				From:     os.Getenv("EMAIL_FROM"),
+				Timeout:  time.Second * 30,
+			},
+			Key:     []byte(key),
+			BaseUrl: baseUrl,
+			Clock:   clock,
+		}
+		emailHandler = &handler.EmailSubscriptionHandler{
+			Client:    binsClient,
+			Reminders: emailReminders,
+		}
+		go reminder.Scheduler{Clock: clock, Hour: 18}.Run(reminders, emailReminders.Send)
+	}
+	// Home Assistant is only told about properties listed in MQTT_PROPERTY_IDS.
+	if mqttUrl := os.Getenv("MQTT_URL"); mqttUrl != "" {
+		mqttClient, err := mqtt.NewClient(mqttUrl)
+		if err != nil {
+			log.Fatal(err)
+		}
+		mqttClient.Timeout = time.Second * 30
+		var propertyIds []string
+		for _, id := range strings.Split(os.Getenv("MQTT_PROPERTY_IDS"), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				propertyIds = append(propertyIds, id)
+			}
+		}
+		if len(propertyIds) == 0 {
+			log.Fatal("MQTT_PROPERTY_IDS must be set to publish to MQTT")
+		}
+		homeAssistant := mqtt.HomeAssistant{
+			Client:          mqttClient,
+			Lookup:          binsAt(&collectionHandler),
+			PropertyIds:     propertyIds,
+			DiscoveryPrefix: os.Getenv("MQTT_DISCOVERY_PREFIX"),
+			Interval:        time.Hour,
+			Clock:           clock,
+		}
+		go homeAssistant.Run(reminders)
 	}
 	readmeHandler := handler.MarkdownHandler{
 		Markdown: readme,
@@ -59,14 +276,169 @@ func main() {
 	}
 
 	r := mux.NewRouter()
+	r.HandleFunc("/property/{property_id}.ics", calendarHandler.Handle)
+	r.HandleFunc("/property/{property_id}/schedule", scheduleHandler.Handle)
 	r.HandleFunc("/property/{property_id}", collectionHandler.Handle)
 	r.HandleFunc("/addresses/{postcode}", addressHandler.Handle)
+	// Before /subscriptions/{id}, which would match the DELETE.
+	if webPushHandler != nil {
+		r.HandleFunc("/subscriptions/webpush/key", webPushHandler.Key).Methods(http.MethodGet)
+		r.HandleFunc("/subscriptions/webpush", subscribeLimiter.Limit(webPushHandler.Create)).Methods(http.MethodPost)
+		r.HandleFunc("/subscriptions/webpush", webPushHandler.Delete).Methods(http.MethodDelete)
+	}
+	if pushSubscriptionHandler != nil {
+		r.HandleFunc("/subscriptions/push", subscribeLimiter.Limit(pushSubscriptionHandler.Create)).Methods(http.MethodPost)
+		r.HandleFunc("/subscriptions/push/{id}", pushSubscriptionHandler.Delete).Methods(http.MethodDelete)
+	}
+	if subscriptionHandler != nil {
+		r.HandleFunc("/subscriptions", subscribeLimiter.Limit(subscriptionHandler.Create)).Methods(http.MethodPost)
+		r.HandleFunc("/subscriptions/{id}", subscriptionHandler.Delete).Methods(http.MethodDelete)
+	}
+	if emailHandler != nil {
+		r.HandleFunc("/subscriptions/email", subscribeLimiter.Limit(emailHandler.Create)).Methods(http.MethodPost)
+		r.HandleFunc(reminder.ConfirmPath, emailHandler.Confirm).Methods(http.MethodGet, http.MethodPost)
+		r.HandleFunc(reminder.UnsubscribePath, emailHandler.Unsubscribe).Methods(http.MethodGet, http.MethodPost)
+	}
+	// The admin endpoint is only served when ADMIN_TOKEN is set.
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		unknownBinTypesHandler := handler.UnknownBinTypesHandler{
+			UnknownBinTypes: unknownBinTypes,
+			Token:           token,
+		}
+		r.HandleFunc("/admin/unknown-bin-types", unknownBinTypesHandler.Handle)
+	}
 	r.PathPrefix("/static/").Handler(http.FileServer(http.FS(static)))
 	r.HandleFunc("/readme", readmeHandler.Handle)
+	// Served from the root so that it can control the whole site.
+	r.HandleFunc("/sw.js", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, static, "static/sw.js")
+	})
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-    	http.ServeFileFS(w, r, static, "static/index.html")
+		http.ServeFileFS(w, r, static, "static/index.html")
 	})
 
+	server := &http.Server{Addr: ":" + port, Handler: r}
+	stopped := make(chan struct{})
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		server.Shutdown(ctx)
+		stopReminders()
+		saveCache()
+		close(stopped)
+	}()
+
 	log.Println("listening on", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-stopped
+}
+
+// Finds what is collected from a property on a day, for sending reminders.
+func collectionOn(h *handler.CollectionHandler) reminder.Lookup {
+	return func(ctx context.Context, propertyId string, date client.Date) (reminder.Collection, bool, error) {
+		p, err := h.Collections(ctx, propertyId)
+		if err != nil {
+			return reminder.Collection{}, false, err
+		}
+		for _, day := range p.Days {
+			if day.Date != date {
+				continue
+			}
+			c := reminder.Collection{PropertyId: p.Id, Name: p.Name, Date: day.Date, Summary: day.Summary}
+			for _, t := range day.Types {
+				c.Types = append(c.Types, t.String())
+			}
+			for _, b := range day.Bins {
+				c.Bins = append(c.Bins, reminder.Bin{Id: b.Id, Name: b.Name, Type: b.Type.String()})
+			}
+			return c, true, nil
+		}
+		return reminder.Collection{}, false, nil
+	}
+}
+
+// Finds when each bin at a property is next collected, for publishing to
+// Home Assistant. Bins with nothing scheduled are left out.
+func binsAt(h *handler.CollectionHandler) mqtt.Lookup {
+	return func(ctx context.Context, propertyId string) (mqtt.Property, error) {
+		p, err := h.Collections(ctx, propertyId)
+		if err != nil {
+			return mqtt.Property{}, err
+		}
+		res := mqtt.Property{Id: p.Id, Name: p.Name}
+		for _, b := range p.Bins {
+			if len(b.Schedule) == 0 {
+				continue
+			}
+			res.Bins = append(res.Bins, mqtt.Bin{Id: b.Id, Name: b.Name, Type: b.Type, NextCollection: b.Schedule[0]})
+		}
+		return res, nil
+	}
+}
+
+// Rules in the file named by BIN_TYPES_FILE are tried before the built-in
+// ones with the same priority, so misclassified bins can be fixed without a
+// new release.
+func newClassifier() *client.Classifier {
+	rules := client.DefaultClassifierRules()
+	if path := os.Getenv("BIN_TYPES_FILE"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		extra, err := client.ReadClassifierRules(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rules = append(extra, rules...)
+	}
+	classifier, err := client.NewClassifier(rules...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return classifier
+}
+
+// Up to 4k cached entries, kept in memory. Set CACHE_FILE to also keep bins
+// and their schedules on disk, so they survive restarts. Everything else is
+// quick enough to fetch again. Set REDIS_URL instead to share one cache
+// between every instance of the service.
+func newCache(clock clockwork.Clock) (client.Cache, func()) {
+	if redisUrl := os.Getenv("REDIS_URL"); redisUrl != "" {
+		cache, err := client.NewRedisCache(redisUrl)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cache.Timeout = time.Second
+		cache.OnError = func(err error) {
+			log.Println("cache error:", err)
+		}
+		return cache, func() {}
+	}
+
+	path := os.Getenv("CACHE_FILE")
+	if path == "" {
+		cache, _ := client.NewMemoryCache(4096, clock)
+		return cache, func() {}
+	}
+
+	cache, _ := client.NewFileCache(path, 4096, clock,
+		client.CachedBinTypes.Name,
+		client.CachedWorkflowIds.Name,
+		client.CachedSchedules.Name,
+	)
+	if err := cache.Load(); err != nil {
+		log.Println("could not load cache from", path, err)
+	}
+	return cache, func() {
+		if err := cache.Save(); err != nil {
+			log.Println("could not save cache to", path, err)
+		}
+	}
 }
@@ -2,9 +2,7 @@ package client
 
 import (
 	"bytes"
-	"encoding/json"
-	"errors"
-	"fmt"
+	"context"
 	"io/ioutil"
 	"net/http"
 	"regexp"
@@ -37,8 +35,8 @@ var hackneyPostcodes = []string{
 var space = regexp.MustCompile(`\s+`)
 var postcode = regexp.MustCompile(`^(?P<outer>[A-Z]{1,2}[0-9][A-Z0-9]?) ?(?P<inner>[0-9][A-Z]{2})$`)
 
-var NotHackneyErr = errors.New("Hackney postcodes must begin with one of " + strings.Join(hackneyPostcodes, ", "))
-var InvalidPostcodeErr = errors.New("Not a valid postcode")
+var NotHackneyErr = invalidInput("Hackney postcodes must begin with one of " + strings.Join(hackneyPostcodes, ", "))
+var InvalidPostcodeErr = invalidInput("Not a valid postcode")
 
 func canonicalize(s string) (string, error) {
 	tidy := strings.ToUpper(space.ReplaceAllString(strings.TrimSpace(s), " "))
@@ -53,10 +51,22 @@ func canonicalize(s string) (string, error) {
 }
 
 func (c BinsClient) GetAddresses(postcode string) ([]Address, error) {
+	return c.GetAddressesContext(context.Background(), postcode)
+}
+
+func (c BinsClient) GetAddressesContext(ctx context.Context, postcode string) ([]Address, error) {
 	canonical, err := canonicalize(postcode)
 	if err != nil {
 		return []Address{}, err
 	}
+	target := c.ApiHost.JoinPath(addressUrl)
+	cacheKey := target.JoinPath(canonical).String()
+	return cached(ctx, c, CachedAddresses, cacheKey, func(ctx context.Context) ([]Address, error) {
+		return c.fetchAddresses(ctx, target.String(), canonical)
+	})
+}
+
+func (c BinsClient) fetchAddresses(ctx context.Context, target string, canonical string) ([]Address, error) {
 	reqBody := []byte(`{
 		"Postcode": "` + canonical + `",
 		"Filters":
@@ -69,16 +79,10 @@ func (c BinsClient) GetAddresses(postcode string) ([]Address, error) {
 			}
 		]
 	}`)
-	target := c.ApiHost.JoinPath(addressUrl)
 
-	cacheKey := target.JoinPath(canonical).String()
-	if c.Cache != nil {
-		if res, found := c.Cache.Get(cacheKey); found {
-			return res.([]Address), nil
-		}
-	}
-
-	req, err := http.NewRequest(http.MethodPost, target.String(), bytes.NewBuffer(reqBody))
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return []Address{}, err
 	}
@@ -86,12 +90,12 @@ func (c BinsClient) GetAddresses(postcode string) ([]Address, error) {
 	req.Header.Add("User-Agent", userAgent)
 	req.Header.Add("Accept", "application/json")
 
-	resp, err := c.HttpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return []Address{}, err
 	}
 	if resp.StatusCode != 200 {
-		return []Address{}, fmt.Errorf("Status code %v fetching addresses for postcode", resp.StatusCode)
+		return []Address{}, c.statusError(resp, "addresses for postcode")
 	}
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -106,7 +110,12 @@ func (c BinsClient) GetAddresses(postcode string) ([]Address, error) {
 	}
 
 	var data result
-	json.Unmarshal(respBody, &data)
+	if err := decodeResponse(addressUrl, respBody, &data); err != nil {
+		return []Address{}, err
+	}
+	if data.AddressSummaries == nil {
+		return []Address{}, missingField(addressUrl, "addressSummaries")
+	}
 
 	var addresses []Address
 	for _, addressSummary := range data.AddressSummaries {
@@ -129,8 +138,5 @@ func (c BinsClient) GetAddresses(postcode string) ([]Address, error) {
 		return 1
 	})
 
-	if c.Cache != nil {
-		c.Cache.Add(cacheKey, addresses)
-	}
 	return addresses, nil
 }
@@ -9,15 +9,13 @@ import (
 	"net/url"
 	"testing"
 	"testing/iotest"
-	"time"
 
 	"github.com/dinosaursrarr/hackney-bindicator/client"
-	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestWrongPostcodeArea(t *testing.T) {
-	client := client.BinsClient{http.Client{}, nil, nil, nil}
+	client := client.BinsClient{HttpClient: http.Client{}}
 	res, err := client.GetAddresses("EH16 5AY")
 
 	assert.Empty(t, res)
@@ -37,7 +35,7 @@ func TestNotPostcode(t *testing.T) {
 		"E8 3üu",
 		"Susan",
 	}
-	client := client.BinsClient{http.Client{}, nil, nil, nil}
+	client := client.BinsClient{HttpClient: http.Client{}}
 
 	for _, test := range tests {
 		res, err := client.GetAddresses(test)
@@ -48,7 +46,7 @@ func TestNotPostcode(t *testing.T) {
 
 func TestBadUrlForAddresses(t *testing.T) {
 	badUrl, _ := url.Parse("ftp://foo.com")
-	client := client.BinsClient{http.Client{}, nil, badUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: badUrl}
 
 	res, err := client.GetAddresses(Postcode)
 
@@ -62,7 +60,7 @@ func TestSetUserAgentGettingAddresses(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	client.GetAddresses(Postcode)
 }
@@ -73,7 +71,7 @@ func TestSetAcceptGettingAddresses(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	client.GetAddresses(Postcode)
 }
@@ -84,7 +82,7 @@ func TestSetContentTypeGettingAddresses(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	client.GetAddresses(Postcode)
 }
@@ -100,7 +98,7 @@ func TestHttpErrorGettingAddresses(t *testing.T) {
 			},
 		},
 	}
-	client := client.BinsClient{httpClient, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: httpClient, ApiHost: apiUrl}
 
 	res, err := client.GetAddresses(Postcode)
 
@@ -114,7 +112,7 @@ func TestBadStatusCodeGettingAddresses(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := client.GetAddresses(Postcode)
 
@@ -136,7 +134,7 @@ func TestErrorReadingAddresses(t *testing.T) {
 			},
 		},
 	}
-	client := client.BinsClient{httpClient, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: httpClient, ApiHost: apiUrl}
 
 	res, err := client.GetAddresses(Postcode)
 
@@ -145,10 +143,12 @@ func TestErrorReadingAddresses(t *testing.T) {
 }
 
 func TestNoAddressesReturned(t *testing.T) {
-	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"addressSummaries": []}`)
+	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := client.GetAddresses(Postcode)
 
@@ -166,10 +166,12 @@ func TestAcceptPostcodeFormats(t *testing.T) {
 		"e8 3QQ",
 		"e83qQ",
 	}
-	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"addressSummaries": []}`)
+	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	for _, test := range tests {
 		res, err := client.GetAddresses(test)
@@ -193,7 +195,7 @@ func TestSuccessAddresses(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	binsClient := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := binsClient.GetAddresses(Postcode)
 
@@ -220,7 +222,7 @@ func TestSkipEmptyName(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	binsClient := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := binsClient.GetAddresses(Postcode)
 
@@ -243,7 +245,7 @@ func TestSkipEmptyId(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	binsClient := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := binsClient.GetAddresses(Postcode)
 
@@ -268,7 +270,7 @@ func TestSuccessFetchTwiceWithoutCache(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	binsClient := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	binsClient.GetAddresses(Postcode)
 	binsClient.GetAddresses(Postcode)
@@ -293,8 +295,8 @@ func TestSuccessFetchOnceWithCache(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	cache := expirable.NewLRU[string, interface{}](1024, nil, time.Minute*10)
-	binsClient := client.BinsClient{http.Client{}, nil, apiUrl, cache}
+	cache, _ := client.NewMemoryCache(1024, nil)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Cache: cache}
 
 	binsClient.GetAddresses(Postcode)
 	binsClient.GetAddresses(Postcode)
@@ -337,7 +339,7 @@ func TestSortByName(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	binsClient := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := binsClient.GetAddresses(Postcode)
 
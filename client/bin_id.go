@@ -1,16 +1,14 @@
 package client
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
+	"context"
 	"io/ioutil"
 	"net/http"
 	"strings"
 )
 
 // Want to be able to distinguish this error from other statuses.
-var ErrBadPropertyId = errors.New("Status code 400 fetching list of bins")
+var ErrBadPropertyId = invalidInput("Status code 400 fetching list of bins")
 
 type BinIds struct {
 	Name string
@@ -18,22 +16,27 @@ type BinIds struct {
 }
 
 func (c BinsClient) GetBinIds(propertyId string) (BinIds, error) {
-	target := c.ApiHost.JoinPath(binIdUrl, propertyId).String()
+	return c.GetBinIdsContext(context.Background(), propertyId)
+}
 
-	if c.Cache != nil {
-		if res, found := c.Cache.Get(target); found {
-			return res.(BinIds), nil
-		}
-	}
+func (c BinsClient) GetBinIdsContext(ctx context.Context, propertyId string) (BinIds, error) {
+	target := c.ApiHost.JoinPath(binIdUrl, propertyId).String()
+	return cached(ctx, c, CachedBinIds, target, func(ctx context.Context) (BinIds, error) {
+		return c.fetchBinIds(ctx, target)
+	})
+}
 
-	req, err := http.NewRequest(http.MethodGet, target, nil)
+func (c BinsClient) fetchBinIds(ctx context.Context, target string) (BinIds, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
 	if err != nil {
 		return BinIds{}, err
 	}
 	req.Header.Add("User-Agent", userAgent)
 	req.Header.Add("Accept", "application/json")
 
-	resp, err := c.HttpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return BinIds{}, err
 	}
@@ -41,7 +44,7 @@ func (c BinsClient) GetBinIds(propertyId string) (BinIds, error) {
 		return BinIds{}, ErrBadPropertyId
 	}
 	if resp.StatusCode != 200 {
-		return BinIds{}, fmt.Errorf("Status code %v fetching list of bins", resp.StatusCode)
+		return BinIds{}, c.statusError(resp, "list of bins")
 	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -50,25 +53,30 @@ func (c BinsClient) GetBinIds(propertyId string) (BinIds, error) {
 
 	// In this case, we want an attribute with a list of string values
 	type item struct {
-		AddressSummary string `json:"addressSummary"`
-		Fields         struct {
-			Containers string `json:"attributes_wasteContainersAssignableWasteContainers"`
+		AddressSummary *string `json:"addressSummary"`
+		Fields         *struct {
+			Containers *string `json:"attributes_wasteContainersAssignableWasteContainers"`
 		} `json:"providerSpecificFields"`
 	}
 
 	var data item
-	json.Unmarshal(body, &data)
+	if err := decodeResponse(binIdUrl, body, &data); err != nil {
+		return BinIds{}, err
+	}
+	if data.AddressSummary == nil {
+		return BinIds{}, missingField(binIdUrl, "addressSummary")
+	}
+	if data.Fields == nil || data.Fields.Containers == nil {
+		return BinIds{}, missingField(binIdUrl, "providerSpecificFields.attributes_wasteContainersAssignableWasteContainers")
+	}
 
-	if len(data.Fields.Containers) == 0 {
-		return BinIds{}, errors.New("Bin IDs not found for property")
+	if len(*data.Fields.Containers) == 0 {
+		return BinIds{}, notFound("Bin IDs not found for property")
 	}
 
 	res := BinIds{
-		Name: tidy(data.AddressSummary),
-		Ids:  strings.Split(data.Fields.Containers, ","),
-	}
-	if c.Cache != nil {
-		c.Cache.Add(target, res)
+		Name: tidy(*data.AddressSummary),
+		Ids:  strings.Split(*data.Fields.Containers, ","),
 	}
 	return res, nil
 }
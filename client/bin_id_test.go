@@ -9,16 +9,14 @@ import (
 	"net/url"
 	"testing"
 	"testing/iotest"
-	"time"
 
 	"github.com/dinosaursrarr/hackney-bindicator/client"
-	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestBadBinIdUrl(t *testing.T) {
 	badUrl, _ := url.Parse("ftp://foo.bar")
-	client := client.BinsClient{http.Client{}, nil, badUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: badUrl}
 
 	res, err := client.GetBinIds(PropertyId)
 
@@ -32,7 +30,7 @@ func TestSetUserAgentGettingBinIds(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	client.GetBinIds(PropertyId)
 }
@@ -43,7 +41,7 @@ func TestSetAcceptGettingBinIds(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	client.GetBinIds(PropertyId)
 }
@@ -59,7 +57,7 @@ func TestHttpErrorGettingBinIds(t *testing.T) {
 			},
 		},
 	}
-	client := client.BinsClient{httpClient, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: httpClient, ApiHost: apiUrl}
 
 	res, err := client.GetBinIds(PropertyId)
 
@@ -73,7 +71,7 @@ func TestStatusCode400GettingBinIds(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := client.GetBinIds(PropertyId)
 
@@ -87,7 +85,7 @@ func TestBadStatusCodeGettingBinIds(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := client.GetBinIds(PropertyId)
 
@@ -109,7 +107,7 @@ func TestErrorReadingBinIds(t *testing.T) {
 			},
 		},
 	}
-	client := client.BinsClient{httpClient, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: httpClient, ApiHost: apiUrl}
 
 	res, err := client.GetBinIds(PropertyId)
 
@@ -117,16 +115,17 @@ func TestErrorReadingBinIds(t *testing.T) {
 	assert.Contains(t, err.Error(), "nope")
 }
 
-func TestBinIdsNotFound(t *testing.T) {
+func TestBinIdsEmptyResponse(t *testing.T) {
 	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
-	res, err := client.GetBinIds(PropertyId)
+	res, err := binsClient.GetBinIds(PropertyId)
 
 	assert.Empty(t, res)
-	assert.Contains(t, err.Error(), "Bin IDs not found")
+	var schemaErr client.ErrUpstreamSchema
+	assert.ErrorAs(t, err, &schemaErr)
 }
 
 func TestNoBinIdsFound(t *testing.T) {
@@ -142,12 +141,13 @@ func TestNoBinIdsFound(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	binsClient := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := binsClient.GetBinIds(PropertyId)
 
 	assert.Empty(t, res)
 	assert.Contains(t, err.Error(), "Bin IDs not found")
+	assert.ErrorIs(t, err, client.ErrNotFound)
 }
 
 func TestSuccessBinIds(t *testing.T) {
@@ -163,7 +163,7 @@ func TestSuccessBinIds(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	binsClient := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := binsClient.GetBinIds(PropertyId)
 
@@ -189,7 +189,7 @@ func TestFetchBinIdsTwiceWithoutCache(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	client.GetBinIds(PropertyId)
 	client.GetBinIds(PropertyId)
@@ -212,8 +212,8 @@ func TestFetchBinIdsOnceWithCache(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	cache := expirable.NewLRU[string, interface{}](1024, nil, time.Minute*10)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, cache}
+	cache, _ := client.NewMemoryCache(1024, nil)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Cache: cache}
 
 	client.GetBinIds(PropertyId)
 	client.GetBinIds(PropertyId)
@@ -1,13 +1,13 @@
 package client
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"strings"
+	"regexp"
+	"strconv"
 )
 
 type RefuseType int
@@ -18,6 +18,12 @@ const (
 	Recycling
 	Garden
 	Rubbish
+	Glass
+	Textiles
+	// Furniture, mattresses and other things too big for a bin.
+	Bulky
+	// Small electrical items and batteries.
+	Electricals
 )
 
 func (r RefuseType) String() string {
@@ -30,99 +36,163 @@ func (r RefuseType) String() string {
 		return "garden"
 	case Rubbish:
 		return "rubbish"
+	case Glass:
+		return "glass"
+	case Textiles:
+		return "textiles"
+	case Bulky:
+		return "bulky"
+	case Electricals:
+		return "electricals"
 	}
 	return "unknown"
 }
 
-type BinType struct {
-	Name string
-	Type RefuseType
+var refuseTypes = []RefuseType{Food, Recycling, Garden, Rubbish, Glass, Textiles, Bulky, Electricals}
+
+func (r RefuseType) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
 }
 
-func extractType(name, type_id string) RefuseType {
-	// This is business logic that I've added. Probably the most
-	// fragile part of this whole app.
-	if type_id == "5f96b455e36673006420c529" {
-		return Food // Food Caddy (Small)
-	}
-	if type_id == "5f96b4a7d1f4f500660f2cde" {
-		return Food // Food Caddy (Large)
-	}
-	if type_id == "5f89bea126b55500675f4d08" {
-		return Recycling // Recycling Sack
-	}
-	if type_id == "5f96b7733278d10067b889e4" {
-		return Recycling // Recycling Reusable Bag (Estate)
-	}
-	if type_id == "5f96b6523278d10067b88883" {
-		return Garden // Garden Waste Reusable Bag
-	}
-	if type_id == "5f96b6f8d1f4f500660f3058" {
-		return Garden // Compostable Liners
-	}
-	if type_id == "5f96b596e36673006420c665" {
-		return Garden // Garden Waste Bin
-	}
-	if type_id == "5f96b7dde6d6ef00671d1a04" {
-		return Garden // Garden Waste Key
-	}
-	if type_id == "659c303fa80cc2bb2ce82627" {
-		return Garden // GW_Wheeled Bin 140l
-	}
-	if type_id == "659c317a91676f249616068c" {
-		return Garden // GW_Bin140l + 2 Bags 90l
-	}
-	if type_id == "5f89be840de3b800682a1ce6" {
-		return Rubbish // Refuse Sack
-	}
-	if type_id == "5f96b8d0e36673006420c9ed" {
-		return Rubbish // Dustbin 90 ltrs x2
-	}
-	if type_id == "5f96b8fce36673006420ca1f" {
-		return Rubbish // Wheeled Bin (180ltr)
-	}
-	if type_id == "600ae93423debf006583d078" {
-		return Rubbish // Dustbin 90 ltrs x1
+func (r *RefuseType) UnmarshalText(text []byte) error {
+	for _, t := range append(refuseTypes, UndefinedRefuseType) {
+		if t.String() == string(text) {
+			*r = t
+			return nil
+		}
 	}
-	if type_id == "619f87d15c9f9c016ce81494" {
-		return Rubbish // Refuse Eurobin (1100 litre) Trade Waste Only
+	return fmt.Errorf("Unknown type of refuse %q", text)
+}
+
+// What sort of thing the refuse goes out in.
+type ContainerKind int
+
+const (
+	UndefinedContainerKind ContainerKind = iota
+	// Bags and sacks, whether thrown away with the refuse or reused.
+	Sack
+	// Small food waste bins with a handle.
+	Caddy
+	WheelieBin
+	// Large bins and banks shared by an estate or block of flats.
+	CommunalContainer
+)
+
+func (k ContainerKind) String() string {
+	switch k {
+	case Sack:
+		return "sack"
+	case Caddy:
+		return "caddy"
+	case WheelieBin:
+		return "wheelie_bin"
+	case CommunalContainer:
+		return "communal"
 	}
+	return "unknown"
+}
+
+var containerKinds = []ContainerKind{Sack, Caddy, WheelieBin, CommunalContainer}
 
-	if strings.Contains(name, "ES_Refuse") {
-		return Rubbish
+func (k ContainerKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *ContainerKind) UnmarshalText(text []byte) error {
+	for _, c := range append(containerKinds, UndefinedContainerKind) {
+		if c.String() == string(text) {
+			*k = c
+			return nil
+		}
 	}
-	if strings.Contains(name, "ES_Recycling") {
-		return Recycling
+	return fmt.Errorf("Unknown kind of container %q", text)
+}
+
+type BinType struct {
+	Name string
+	Type RefuseType
+	// The Council's ID for this type of container.
+	TypeId string `json:",omitempty"`
+	// What to call this type of container, for people, if known.
+	Label string `json:",omitempty"`
+	Kind  ContainerKind
+	// Shared by a block of flats or an estate, rather than one household.
+	Communal bool `json:",omitempty"`
+	// For businesses, rather than households.
+	Trade bool `json:",omitempty"`
+	// How much one container holds, in litres. Zero if not known.
+	Capacity int `json:",omitempty"`
+}
+
+// Sizes in names such as "Wheeled Bin (180ltr)", "Dustbin 90 ltrs x2" or
+// "ES_Refuse 1100L". Where a name gives more than one, the first is used.
+var capacity = regexp.MustCompile(`(?i)(\d+)\s*(?:l|ltrs?|litres?|liters?)\b`)
+
+func parseCapacity(name string) int {
+	m := capacity.FindStringSubmatch(name)
+	if m == nil {
+		return 0
 	}
-	if strings.Contains(name, "ES_Food") {
-		return Food
+	litres, _ := strconv.Atoi(m[1])
+	return litres
+}
+
+func (c BinsClient) classifier() *Classifier {
+	if c.Classifier == nil {
+		return defaultClassifier()
 	}
+	return c.Classifier
+}
 
-	return UndefinedRefuseType
+// Works out the type every time, rather than trusting the cache, so that
+// changes to the rules take effect straight away.
+func (c BinsClient) classify(b BinType) BinType {
+	class := c.classifier().Classify(b.Name, b.TypeId)
+	b.Type = class.Type
+	b.Label = class.Label
+	b.Kind = class.Kind
+	b.Communal = class.Communal
+	b.Trade = class.Trade
+	// The name is what the Council calls this particular container, so it is
+	// more likely to be right than the label for its type.
+	b.Capacity = parseCapacity(b.Name)
+	if b.Capacity == 0 {
+		b.Capacity = parseCapacity(b.Label)
+	}
+	return b
 }
 
 func (c BinsClient) GetBinType(binId string) (BinType, error) {
-	target := c.ApiHost.JoinPath(binTypeUrl, binId).String()
+	return c.GetBinTypeContext(context.Background(), binId)
+}
 
-	if c.Cache != nil {
-		if res, found := c.Cache.Get(target); found {
-			return res.(BinType), nil
-		}
+func (c BinsClient) GetBinTypeContext(ctx context.Context, binId string) (BinType, error) {
+	target := c.ApiHost.JoinPath(binTypeUrl, binId).String()
+	binType, err := cached(ctx, c, CachedBinTypes, target, func(ctx context.Context) (BinType, error) {
+		return c.fetchBinType(ctx, binId, target)
+	})
+	if err != nil {
+		return BinType{}, err
 	}
+	return c.classify(binType), nil
+}
 
-	req, err := http.NewRequest(http.MethodGet, target, nil)
+func (c BinsClient) fetchBinType(ctx context.Context, binId string, target string) (BinType, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
 	if err != nil { // Don't think this can fail
 		return BinType{}, err
 	}
 	req.Header.Add("User-Agent", userAgent)
 	req.Header.Add("Accept", "application/json")
 
-	resp, err := c.HttpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return BinType{}, err
 	}
 	if resp.StatusCode != 200 {
-		return BinType{}, fmt.Errorf("Status code %v fetching types of bins", resp.StatusCode)
+		return BinType{}, c.statusError(resp, "types of bins")
 	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -131,31 +201,38 @@ func (c BinsClient) GetBinType(binId string) (BinType, error) {
 
 	// In this case, we want a single-stringed attribute value
 	type item struct {
-		SubTitle string `json:"subTitle"`
-		BinType  string `json:"binType"`
+		SubTitle *string `json:"subTitle"`
+		BinType  *string `json:"binType"`
 	}
 
 	var data item
-	json.Unmarshal(body, &data)
-
-	name := tidy(data.SubTitle)
-	refuseType := extractType(name, data.BinType)
-
-	if refuseType == UndefinedRefuseType {
-		log.Printf("unknown bin type: %#v", data)
+	if err := decodeResponse(binTypeUrl, body, &data); err != nil {
+		return BinType{}, err
+	}
+	// Either is enough to work out what sort of bin it is.
+	if data.SubTitle == nil && data.BinType == nil {
+		return BinType{}, missingField(binTypeUrl, "subTitle")
 	}
 
-	if name == "" && refuseType == UndefinedRefuseType {
-		return BinType{}, errors.New("Bin type not found")
+	var res BinType
+	if data.SubTitle != nil {
+		res.Name = tidy(*data.SubTitle)
 	}
+	if data.BinType != nil {
+		res.TypeId = *data.BinType
+	}
+	res = c.classify(res)
 
-	res := BinType{
-		Name: name,
-		Type: refuseType,
+	if res.Type == UndefinedRefuseType {
+		log.Printf("unknown bin type: subTitle %q, binType %q", res.Name, res.TypeId)
+		if c.UnknownBinTypes != nil {
+			c.UnknownBinTypes.record(binId, res)
+		}
 	}
 
-	if c.Cache != nil {
-		c.Cache.Add(target, res)
+	if res.Name == "" && res.Type == UndefinedRefuseType {
+		return BinType{}, notFound("Bin type not found")
 	}
+
 	return res, nil
 }
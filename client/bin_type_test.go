@@ -9,16 +9,14 @@ import (
 	"net/url"
 	"testing"
 	"testing/iotest"
-	"time"
 
 	"github.com/dinosaursrarr/hackney-bindicator/client"
-	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestBadBinTypeUrl(t *testing.T) {
 	badUrl, _ := url.Parse("ftp://foo.bar")
-	client := client.BinsClient{http.Client{}, nil, badUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: badUrl}
 
 	res, err := client.GetBinType(BinId)
 
@@ -32,7 +30,7 @@ func TestSetUserAgentGettingBinType(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	client.GetBinType(BinId)
 }
@@ -43,7 +41,7 @@ func TestSetAcceptGettingBinType(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	client.GetBinType(BinId)
 }
@@ -59,7 +57,7 @@ func TestHttpErrorGettingBinType(t *testing.T) {
 			},
 		},
 	}
-	client := client.BinsClient{httpClient, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: httpClient, ApiHost: apiUrl}
 
 	res, err := client.GetBinType(BinId)
 
@@ -73,7 +71,7 @@ func TestBadStatusCodeGettingBinType(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := client.GetBinType(BinId)
 
@@ -95,7 +93,7 @@ func TestErrorReadingBinType(t *testing.T) {
 			},
 		},
 	}
-	client := client.BinsClient{httpClient, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: httpClient, ApiHost: apiUrl}
 
 	res, err := client.GetBinType(BinId)
 
@@ -103,16 +101,17 @@ func TestErrorReadingBinType(t *testing.T) {
 	assert.Contains(t, err.Error(), "nope")
 }
 
-func TestBinTypeNotFound(t *testing.T) {
+func TestBinTypeEmptyResponse(t *testing.T) {
 	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
-	res, err := client.GetBinType(BinId)
+	res, err := binsClient.GetBinType(BinId)
 
 	assert.Empty(t, res)
-	assert.Contains(t, err.Error(), "Bin type not found")
+	var schemaErr client.ErrUpstreamSchema
+	assert.ErrorAs(t, err, &schemaErr)
 }
 
 func TestEmptyBinTypeFound(t *testing.T) {
@@ -125,7 +124,7 @@ func TestEmptyBinTypeFound(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := client.GetBinType(BinId)
 
@@ -144,11 +143,11 @@ func TestSuccessBinType(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	binsClient := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := binsClient.GetBinType(BinId)
 
-	assert.Equal(t, client.BinType{Name: "Garbage sack", Type: client.Food}, res)
+	assert.Equal(t, client.BinType{Name: "Garbage sack", Type: client.Food, TypeId: "5f96b455e36673006420c529", Label: "Food Caddy (Small)", Kind: client.Caddy}, res)
 	assert.Nil(t, err)
 }
 
@@ -162,7 +161,7 @@ func TestSuccessBinTypeName(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	binsClient := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := binsClient.GetBinType(BinId)
 
@@ -180,11 +179,11 @@ func TestSuccessBinTypeType(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	binsClient := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := binsClient.GetBinType(BinId)
 
-	assert.Equal(t, res, client.BinType{Type: client.Food})
+	assert.Equal(t, res, client.BinType{Type: client.Food, TypeId: "5f96b455e36673006420c529", Label: "Food Caddy (Small)", Kind: client.Caddy})
 	assert.Nil(t, err)
 }
 
@@ -201,7 +200,7 @@ func TestFetchBinTypeTwiceWithoutCache(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	client.GetBinType(BinId)
 	client.GetBinType(BinId)
@@ -222,11 +221,36 @@ func TestFetchBinTypeOnceWithCache(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	cache := expirable.NewLRU[string, interface{}](1024, nil, time.Minute*10)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, cache}
+	cache, _ := client.NewMemoryCache(1024, nil)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Cache: cache}
 
 	client.GetBinType(BinId)
 	client.GetBinType(BinId)
 
 	assert.Equal(t, fetches, 1)
 }
+
+func TestBinTypeCapacity(t *testing.T) {
+	tests := []struct {
+		subTitle string
+		binType  string
+		want     int
+	}{
+		{"Wheeled Bin (180ltr)", "", 180},
+		{"Dustbin 90 ltrs x2", "", 90},
+		{"ES_Refuse 1100L", "", 1100},
+		{"GW_Bin140l + 2 Bags 90l", "", 140},
+		{"Food Caddy 23 Litres", "", 23},
+		{"Refuse Sack", "", 0},
+		{"Lid 2", "", 0},
+		// Falls back to the label for the type of container.
+		{"Bin", "5f96b8fce36673006420ca1f", 180},
+	}
+	for _, test := range tests {
+		c := clientReturning(t, fmt.Sprintf(`{"subTitle": %q, "binType": %q}`, test.subTitle, test.binType))
+
+		res, _ := c.GetBinType(BinId)
+
+		assert.Equal(t, test.want, res.Capacity, test.subTitle)
+	}
+}
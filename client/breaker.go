@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+var ErrCircuitOpen = errors.New("Council API is unavailable, try again later")
+
+// Returned instead of calling the Council's API while it is known to be down.
+type CircuitOpenError struct {
+	// How long until the API will be tried again.
+	RetryAfter time.Duration
+}
+
+func (e CircuitOpenError) Error() string {
+	return ErrCircuitOpen.Error()
+}
+
+func (e CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen || target == ErrUpstreamUnavailable
+}
+
+// Stops calls to the Council's API for a while after it fails repeatedly,
+// so that callers get a quick answer rather than waiting on requests that
+// are going to fail anyway. Once the cooldown has passed, a single call is
+// let through to see whether the API has recovered. Safe for concurrent use,
+// but must not be copied after first use.
+type CircuitBreaker struct {
+	// Consecutive failures before calls stop.
+	Threshold int
+	// How long calls stop for before checking whether the API has recovered.
+	Cooldown time.Duration
+	// Nil means the real clock.
+	Clock clockwork.Clock
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+type outcome int
+
+const (
+	succeeded outcome = iota
+	failed
+	// The caller gave up, so we learned nothing about the API's health.
+	abandoned
+)
+
+func (b *CircuitBreaker) now() time.Time {
+	if b.Clock == nil {
+		return time.Now()
+	}
+	return b.Clock.Now()
+}
+
+func (b *CircuitBreaker) open() bool {
+	return b.Threshold > 0 && b.failures >= b.Threshold
+}
+
+// Returns an error if the call should not be made. Otherwise the caller must
+// report what happened using record.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open() {
+		return nil
+	}
+	remaining := b.openedAt.Add(b.Cooldown).Sub(b.now())
+	if remaining > 0 {
+		return CircuitOpenError{RetryAfter: remaining}
+	}
+	if b.probing {
+		// Someone else is already checking whether the API has recovered.
+		return CircuitOpenError{RetryAfter: time.Second}
+	}
+	b.probing = true
+	return nil
+}
+
+func (b *CircuitBreaker) record(o outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasProbe := b.probing
+	b.probing = false
+	switch o {
+	case succeeded:
+		b.failures = 0
+	case failed:
+		b.failures++
+		if wasProbe || b.failures == b.Threshold {
+			b.openedAt = b.now()
+		}
+	}
+}
+
+func outcomeOf(resp *http.Response, err error) outcome {
+	if errors.Is(err, context.Canceled) {
+		return abandoned
+	}
+	if err != nil || resp.StatusCode >= 500 {
+		return failed
+	}
+	return succeeded
+}
@@ -0,0 +1,184 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitOpensAfterRepeatedFailures(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := flappingServer(100, http.StatusServiceUnavailable, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	breaker := &client.CircuitBreaker{Threshold: 3, Cooldown: time.Minute, Clock: clock}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Breaker: breaker}
+
+	for i := 0; i < 3; i++ {
+		_, err := binsClient.GetBinIds(PropertyId)
+		assert.Contains(t, err.Error(), "Status code 503")
+	}
+	clock.Advance(time.Second * 20)
+	_, err := binsClient.GetBinIds(PropertyId)
+
+	assert.ErrorIs(t, err, client.ErrCircuitOpen)
+	var open client.CircuitOpenError
+	assert.True(t, errors.As(err, &open))
+	assert.Equal(t, time.Second*40, open.RetryAfter)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestCircuitCountsTransportErrors(t *testing.T) {
+	apiUrl, _ := url.Parse("http://foo.bar")
+	httpClient := http.Client{
+		Transport: fakeRoundTripper{
+			Fn: func(req *http.Request) (*http.Response, error) {
+				return nil, errors.New("connection refused")
+			},
+		},
+	}
+	clock := clockwork.NewFakeClock()
+	breaker := &client.CircuitBreaker{Threshold: 2, Cooldown: time.Minute, Clock: clock}
+	binsClient := client.BinsClient{HttpClient: httpClient, ApiHost: apiUrl, Breaker: breaker}
+
+	binsClient.GetBinType(BinId)
+	binsClient.GetBinWorkflowIds(BinId)
+	_, err := binsClient.GetWorkflowSchedule(WorkflowId)
+
+	assert.ErrorIs(t, err, client.ErrCircuitOpen)
+}
+
+func TestCircuitIgnoresClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := flappingServer(100, http.StatusBadRequest, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	breaker := &client.CircuitBreaker{Threshold: 1, Cooldown: time.Minute}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Breaker: breaker}
+
+	binsClient.GetBinIds(PropertyId)
+	_, err := binsClient.GetBinIds(PropertyId)
+
+	assert.Equal(t, client.ErrBadPropertyId, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCircuitIgnoresCancelledCalls(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	breaker := &client.CircuitBreaker{Threshold: 1, Cooldown: time.Minute}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Breaker: breaker}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	binsClient.GetBinIdsContext(ctx, PropertyId)
+	_, err := binsClient.GetBinIds(PropertyId)
+
+	assert.NotErrorIs(t, err, client.ErrCircuitOpen)
+}
+
+func TestCircuitSuccessResetsFailures(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := flappingServer(1, http.StatusServiceUnavailable, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	breaker := &client.CircuitBreaker{Threshold: 2, Cooldown: time.Minute}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Breaker: breaker}
+
+	binsClient.GetBinIds(PropertyId) // Fails
+	binsClient.GetBinIds(PropertyId) // Succeeds
+	calls.Store(0)
+	binsClient.GetBinIds(PropertyId) // Fails, but only once in a row
+	_, err := binsClient.GetBinIds(PropertyId)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCircuitClosesAfterSuccessfulProbe(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := flappingServer(2, http.StatusServiceUnavailable, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	breaker := &client.CircuitBreaker{Threshold: 2, Cooldown: time.Minute, Clock: clock}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Breaker: breaker}
+
+	binsClient.GetBinIds(PropertyId)
+	binsClient.GetBinIds(PropertyId)
+	_, err := binsClient.GetBinIds(PropertyId)
+	assert.ErrorIs(t, err, client.ErrCircuitOpen)
+
+	clock.Advance(time.Minute)
+	_, err = binsClient.GetBinIds(PropertyId)
+	assert.Nil(t, err)
+	_, err = binsClient.GetBinIds(PropertyId)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestCircuitReopensAfterFailedProbe(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := flappingServer(100, http.StatusServiceUnavailable, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	breaker := &client.CircuitBreaker{Threshold: 2, Cooldown: time.Minute, Clock: clock}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Breaker: breaker}
+
+	binsClient.GetBinIds(PropertyId)
+	binsClient.GetBinIds(PropertyId)
+	clock.Advance(time.Minute)
+	_, err := binsClient.GetBinIds(PropertyId)
+	assert.Contains(t, err.Error(), "Status code 503")
+
+	clock.Advance(time.Second * 59)
+	_, err = binsClient.GetBinIds(PropertyId)
+	assert.ErrorIs(t, err, client.ErrCircuitOpen)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestCircuitOnlyOneProbeAtATime(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 1 {
+			http.Error(w, "nope", http.StatusServiceUnavailable)
+			return
+		}
+		<-release
+		w.Write([]byte(binIdsJsonResponse))
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	breaker := &client.CircuitBreaker{Threshold: 1, Cooldown: time.Minute, Clock: clock}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Breaker: breaker}
+
+	binsClient.GetBinIds(PropertyId)
+	clock.Advance(time.Minute)
+	probe := make(chan error)
+	go func() {
+		_, err := binsClient.GetBinIds(PropertyId)
+		probe <- err
+	}()
+	for calls.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	_, err := binsClient.GetBinIds(PropertyId)
+	assert.ErrorIs(t, err, client.ErrCircuitOpen)
+
+	close(release)
+	assert.Nil(t, <-probe)
+}
@@ -0,0 +1,210 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Somewhere to keep answers from the Council's API, and responses built from
+// them, so they don't have to be fetched again. Entries are grouped into
+// namespaces, one for each kind of thing stored. Use a Namespace to read and
+// write entries rather than calling these methods directly.
+type Cache interface {
+	Get(namespace, key string) (interface{}, bool)
+	// Zero ttl means keep the entry until it is evicted.
+	Add(namespace, key string, value interface{}, ttl time.Duration)
+}
+
+// How long to keep each kind of thing in a Cache. Zero means until it is evicted.
+type TTLs struct {
+	Addresses   time.Duration
+	BinIds      time.Duration
+	BinTypes    time.Duration
+	WorkflowIds time.Duration
+	Schedules   time.Duration
+	Responses   time.Duration
+}
+
+// One kind of thing kept in a Cache, all with the same type.
+type Namespace[T any] struct {
+	Name string
+	ttl  func(TTLs) time.Duration
+}
+
+// How to turn the JSON for an entry back into a value, for caches that have
+// to store entries as bytes. Keyed by namespace name.
+var decoders = map[string]func([]byte) (interface{}, error){}
+
+func newNamespace[T any](name string, ttl func(TTLs) time.Duration) Namespace[T] {
+	decoders[name] = func(data []byte) (interface{}, error) {
+		var value T
+		err := json.Unmarshal(data, &value)
+		return value, err
+	}
+	return Namespace[T]{Name: name, ttl: ttl}
+}
+
+func decode(namespace string, data []byte) (interface{}, error) {
+	decoder, ok := decoders[namespace]
+	if !ok {
+		return nil, fmt.Errorf("Unknown cache namespace %v", namespace)
+	}
+	return decoder(data)
+}
+
+var CachedAddresses = newNamespace[[]Address]("addresses", func(t TTLs) time.Duration { return t.Addresses })
+var CachedBinIds = newNamespace[BinIds]("bin_ids", func(t TTLs) time.Duration { return t.BinIds })
+var CachedBinTypes = newNamespace[BinType]("bin_types", func(t TTLs) time.Duration { return t.BinTypes })
+var CachedWorkflowIds = newNamespace[[]string]("workflow_ids", func(t TTLs) time.Duration { return t.WorkflowIds })
+var CachedSchedules = newNamespace[[]time.Time]("schedules", func(t TTLs) time.Duration { return t.Schedules })
+
+// Rendered responses from this API's own handlers.
+var CachedResponses = newNamespace[string]("responses", func(t TTLs) time.Duration { return t.Responses })
+
+// Returns the entry for key, if c is not nil and holds one of the right type.
+func (n Namespace[T]) Get(c Cache, key string) (T, bool) {
+	var zero T
+	if c == nil {
+		return zero, false
+	}
+	res, found := c.Get(n.Name, key)
+	if !found {
+		return zero, false
+	}
+	value, ok := res.(T)
+	return value, ok
+}
+
+// Stores value for key, for as long as ttls says, unless c is nil.
+func (n Namespace[T]) Add(c Cache, ttls TTLs, key string, value T) {
+	if c == nil {
+		return
+	}
+	c.Add(n.Name, key, value, n.ttl(ttls))
+}
+
+// Stores value for key for ttl, instead of for as long as the TTLs for the
+// namespace would say, unless c is nil.
+func (n Namespace[T]) AddFor(c Cache, key string, value T, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+	c.Add(n.Name, key, value, ttl)
+}
+
+// What the stale cache holds: the last value successfully fetched for a key.
+type staleEntry struct {
+	value   interface{}
+	fetched time.Time
+}
+
+// Returns the cached value for key if there is one, otherwise calls fetch and
+// caches what it returns. If fetch fails because the API is unavailable but an
+// earlier call succeeded, the value from then is returned instead, and its age
+// is noted in ctx. A value recent enough to serve while revalidating is
+// returned straight away, and fetched again in the background. Its age isn't
+// noted, as nothing has failed.
+func cached[T any](ctx context.Context, c BinsClient, ns Namespace[T], key string, fetch func(context.Context) (T, error)) (T, error) {
+	if res, found := ns.Get(c.Cache, key); found {
+		return res, nil
+	}
+
+	// Cache the result before anyone sharing the call sees it, so that callers
+	// arriving afterwards find it there rather than calling the API again.
+	refresh := func(ctx context.Context) (T, error) {
+		return coalesced(ctx, c, key, func(ctx context.Context) (T, error) {
+			res, err := fetch(ctx)
+			if err != nil {
+				return res, err
+			}
+			ns.Add(c.Cache, c.CacheTTLs, key, res)
+			if c.StaleCache != nil {
+				c.StaleCache.Add(key, staleEntry{value: res, fetched: c.now()})
+			}
+			return res, nil
+		})
+	}
+
+	stale, hasStale := c.stale(key)
+	age := c.now().Sub(stale.fetched)
+	if hasStale && c.StaleWhileRevalidate > 0 && age <= c.StaleWhileRevalidate {
+		go func() {
+			if _, err := refresh(context.WithoutCancel(ctx)); err != nil {
+				log.Println("could not revalidate", key, err)
+			}
+		}()
+		return stale.value.(T), nil
+	}
+
+	res, err := refresh(ctx)
+	if err != nil && transient(err) {
+		if stale, ok := c.stale(key); ok {
+			age := c.now().Sub(stale.fetched)
+			if c.MaxStaleness <= 0 || age <= c.MaxStaleness {
+				recordStaleness(ctx, age)
+				return stale.value.(T), nil
+			}
+		}
+	}
+	return res, err
+}
+
+// Whether err means the API might well work if called again later, so that
+// an old answer is better than none. Answers that can never work, such as a
+// property not being found, are passed on instead.
+func transient(err error) bool {
+	return errors.Is(err, ErrUpstreamUnavailable) || errors.Is(err, ErrRateLimited)
+}
+
+func (c BinsClient) stale(key string) (staleEntry, bool) {
+	if c.StaleCache == nil {
+		return staleEntry{}, false
+	}
+	res, found := c.StaleCache.Get(key)
+	if !found {
+		return staleEntry{}, false
+	}
+	return res.(staleEntry), true
+}
+
+type stalenessKey struct{}
+
+// Keeps track of whether any call made with a context returned stale data
+// because the Council's API failed, and if so how old the oldest data was.
+type Staleness struct {
+	mu    sync.Mutex
+	stale bool
+	age   time.Duration
+}
+
+// Returns a context that records stale results from the client into the
+// returned Staleness.
+func TrackStaleness(ctx context.Context) (context.Context, *Staleness) {
+	s := &Staleness{}
+	return context.WithValue(ctx, stalenessKey{}, s), s
+}
+
+func recordStaleness(ctx context.Context, age time.Duration) {
+	if s, ok := ctx.Value(stalenessKey{}).(*Staleness); ok {
+		s.record(age)
+	}
+}
+
+func (s *Staleness) record(age time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stale = true
+	s.age = max(s.age, age)
+}
+
+// Reports how old the oldest stale result was, if there were any.
+func (s *Staleness) Age() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.age, s.stale
+}
@@ -0,0 +1,242 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+// Succeeds the first time it is called, then fails.
+func failAfterFirstServer(calls *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			http.Error(w, "nope", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, binIdsJsonResponse)
+	}))
+}
+
+func TestFallBackOnStaleCopy(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := failAfterFirstServer(&calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	staleCache, _ := lru.New[string, interface{}](1024)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, StaleCache: staleCache}
+
+	first, err := binsClient.GetBinIds(PropertyId)
+	assert.Nil(t, err)
+	clock.Advance(time.Hour)
+	ctx, staleness := client.TrackStaleness(context.Background())
+	second, err := binsClient.GetBinIdsContext(ctx, PropertyId)
+
+	assert.Nil(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(2), calls.Load())
+	age, stale := staleness.Age()
+	assert.True(t, stale)
+	assert.Equal(t, time.Hour, age)
+}
+
+func TestNotStaleWhenFetchSucceeds(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, binIdsJsonResponse)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	staleCache, _ := lru.New[string, interface{}](1024)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, StaleCache: staleCache}
+
+	ctx, staleness := client.TrackStaleness(context.Background())
+	binsClient.GetBinIdsContext(ctx, PropertyId)
+	binsClient.GetBinIdsContext(ctx, PropertyId)
+
+	_, stale := staleness.Age()
+	assert.False(t, stale)
+}
+
+func TestNoFallBackWithoutStaleCache(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := failAfterFirstServer(&calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
+
+	binsClient.GetBinIds(PropertyId)
+	_, err := binsClient.GetBinIds(PropertyId)
+
+	assert.Contains(t, err.Error(), "Status code 500")
+}
+
+func TestNoFallBackOnCopyThatIsTooOld(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := failAfterFirstServer(&calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	staleCache, _ := lru.New[string, interface{}](1024)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, StaleCache: staleCache, MaxStaleness: time.Hour}
+
+	binsClient.GetBinIds(PropertyId)
+	clock.Advance(time.Hour + time.Second)
+	_, err := binsClient.GetBinIds(PropertyId)
+
+	assert.Contains(t, err.Error(), "Status code 500")
+}
+
+func TestTrackOldestStaleCopy(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 2 {
+			http.Error(w, "nope", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, binIdsJsonResponse)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	staleCache, _ := lru.New[string, interface{}](1024)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, StaleCache: staleCache}
+
+	binsClient.GetBinIds("old")
+	clock.Advance(time.Hour)
+	binsClient.GetBinIds("new")
+	clock.Advance(time.Minute)
+	ctx, staleness := client.TrackStaleness(context.Background())
+	binsClient.GetBinIdsContext(ctx, "new")
+	binsClient.GetBinIdsContext(ctx, "old")
+
+	age, stale := staleness.Age()
+	assert.True(t, stale)
+	assert.Equal(t, time.Hour+time.Minute, age)
+}
+
+func TestFallBackWhenCircuitOpen(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := failAfterFirstServer(&calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	staleCache, _ := lru.New[string, interface{}](1024)
+	breaker := &client.CircuitBreaker{Threshold: 1, Cooldown: time.Minute}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, StaleCache: staleCache, Breaker: breaker}
+
+	binsClient.GetBinIds(PropertyId)
+	binsClient.GetBinIds(PropertyId) // Opens the circuit
+	res, err := binsClient.GetBinIds(PropertyId)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo", "bar"}, res.Ids)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestNoFallBackWhenPropertyIsBad(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			http.Error(w, "nope", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, binIdsJsonResponse)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	staleCache, _ := lru.New[string, interface{}](1024)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, StaleCache: staleCache}
+
+	binsClient.GetBinIds(PropertyId)
+	_, err := binsClient.GetBinIds(PropertyId)
+
+	assert.ErrorIs(t, err, client.ErrBadPropertyId)
+}
+
+func TestRevalidateInBackground(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			fmt.Fprint(w, strings.Replace(binIdsJsonResponse, "foo", "baz", 1))
+			return
+		}
+		fmt.Fprintf(w, binIdsJsonResponse)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	cache, _ := client.NewMemoryCache(1024, clock)
+	staleCache, _ := lru.New[string, interface{}](1024)
+	binsClient := client.BinsClient{
+		HttpClient:           http.Client{},
+		Clock:                clock,
+		ApiHost:              apiUrl,
+		Cache:                cache,
+		CacheTTLs:            client.TTLs{BinIds: time.Hour},
+		StaleCache:           staleCache,
+		StaleWhileRevalidate: time.Hour * 2,
+		// Shares the refresh with calls made while waiting for it.
+		Coalescer: &client.Coalescer{},
+	}
+
+	binsClient.GetBinIds(PropertyId)
+	clock.Advance(time.Hour + time.Minute)
+	ctx, staleness := client.TrackStaleness(context.Background())
+	old, err := binsClient.GetBinIdsContext(ctx, PropertyId)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo", "bar"}, old.Ids)
+	// Only answers given because the API failed count as stale.
+	_, isStale := staleness.Age()
+	assert.False(t, isStale)
+	assert.Eventually(t, func() bool {
+		res, _ := binsClient.GetBinIds(PropertyId)
+		return res.Ids[0] == "baz"
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestWaitForFreshCopyWhenTooOldToRevalidate(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			fmt.Fprint(w, strings.Replace(binIdsJsonResponse, "foo", "baz", 1))
+			return
+		}
+		fmt.Fprintf(w, binIdsJsonResponse)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	cache, _ := client.NewMemoryCache(1024, clock)
+	staleCache, _ := lru.New[string, interface{}](1024)
+	binsClient := client.BinsClient{
+		HttpClient:           http.Client{},
+		Clock:                clock,
+		ApiHost:              apiUrl,
+		Cache:                cache,
+		CacheTTLs:            client.TTLs{BinIds: time.Hour},
+		StaleCache:           staleCache,
+		StaleWhileRevalidate: time.Hour * 2,
+	}
+
+	binsClient.GetBinIds(PropertyId)
+	clock.Advance(time.Hour * 3)
+	ctx, staleness := client.TrackStaleness(context.Background())
+	res, err := binsClient.GetBinIdsContext(ctx, PropertyId)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"baz", "bar"}, res.Ids)
+	_, isStale := staleness.Age()
+	assert.False(t, isStale)
+}
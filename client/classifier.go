@@ -0,0 +1,152 @@
+package client
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+// The rules built into the app for working out what sort of refuse each
+// bin is for. The Council's API doesn't say, so these are guesses from the
+// container types and names seen so far.
+//
+//go:embed bin_types.json
+var defaultClassifierRules []byte
+
+// One way of recognising a type of container. Exactly one of Id, Name and
+// Pattern must be set.
+type ClassifierRule struct {
+	// Matches the container type ID the Council's API gives as binType.
+	Id string `json:"id,omitempty"`
+	// Matches the whole of the container's name, after tidying spaces.
+	Name string `json:"name,omitempty"`
+	// A regular expression that matches somewhere in the container's name.
+	Pattern string     `json:"pattern,omitempty"`
+	Type    RefuseType `json:"type"`
+	// What to call this kind of container, for people.
+	Label string        `json:"label,omitempty"`
+	Kind  ContainerKind `json:"kind,omitempty"`
+	// Shared by a block of flats or an estate, rather than one household.
+	Communal bool `json:"communal,omitempty"`
+	// For businesses, rather than households.
+	Trade bool `json:"trade,omitempty"`
+	// Rules with higher priorities are tried first. Rules with the same
+	// priority are tried in the order given.
+	Priority int `json:"priority,omitempty"`
+}
+
+type classifierRules struct {
+	Rules []ClassifierRule `json:"rules"`
+}
+
+type compiledRule struct {
+	ClassifierRule
+	pattern *regexp.Regexp
+}
+
+func (r compiledRule) matches(name, typeId string) bool {
+	switch {
+	case r.Id != "":
+		return r.Id == typeId
+	case r.Name != "":
+		return r.Name == name
+	default:
+		return r.pattern.MatchString(name)
+	}
+}
+
+// Works out what sort of refuse a bin is for from what the Council's API
+// says about it, using a list of rules. Safe for concurrent use.
+type Classifier struct {
+	rules []compiledRule
+}
+
+// Reads rules in the same JSON format as the ones built into the app.
+func ReadClassifierRules(r io.Reader) ([]ClassifierRule, error) {
+	var data classifierRules
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, fmt.Errorf("Could not read bin type rules: %w", err)
+	}
+	return data.Rules, nil
+}
+
+// The rules built into the app.
+func DefaultClassifierRules() []ClassifierRule {
+	rules, err := ReadClassifierRules(bytes.NewReader(defaultClassifierRules))
+	if err != nil {
+		panic(err) // Checked by tests
+	}
+	return rules
+}
+
+func NewClassifier(rules ...ClassifierRule) (*Classifier, error) {
+	var compiled []compiledRule
+	for i, rule := range rules {
+		set := 0
+		for _, s := range []string{rule.Id, rule.Name, rule.Pattern} {
+			if s != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("Bin type rule %v must have exactly one of id, name and pattern", i)
+		}
+		if rule.Type == UndefinedRefuseType {
+			return nil, fmt.Errorf("Bin type rule %v must have a type", i)
+		}
+		c := compiledRule{ClassifierRule: rule}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("Bin type rule %v has a bad pattern: %w", i, err)
+			}
+			c.pattern = pattern
+		}
+		compiled = append(compiled, c)
+	}
+	slices.SortStableFunc(compiled, func(a, b compiledRule) int {
+		return b.Priority - a.Priority
+	})
+	return &Classifier{rules: compiled}, nil
+}
+
+var defaultClassifier = sync.OnceValue(func() *Classifier {
+	classifier, err := NewClassifier(DefaultClassifierRules()...)
+	if err != nil {
+		panic(err) // Checked by tests
+	}
+	return classifier
+})
+
+// What a Classifier says about a type of container.
+type Classification struct {
+	Type     RefuseType
+	Label    string
+	Kind     ContainerKind
+	Communal bool
+	Trade    bool
+}
+
+// Uses the first rule that matches a container's name or type ID. The Type is
+// UndefinedRefuseType if none do.
+func (c *Classifier) Classify(name, typeId string) Classification {
+	for _, rule := range c.rules {
+		if rule.matches(name, typeId) {
+			return Classification{
+				Type:     rule.Type,
+				Label:    rule.Label,
+				Kind:     rule.Kind,
+				Communal: rule.Communal,
+				Trade:    rule.Trade,
+			}
+		}
+	}
+	return Classification{}
+}
@@ -0,0 +1,218 @@
+package client_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultClassifierRules(t *testing.T) {
+	classifier, err := client.NewClassifier(client.DefaultClassifierRules()...)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name   string
+		typeId string
+		want   client.RefuseType
+	}{
+		{"", "5f96b455e36673006420c529", client.Food},
+		{"", "5f89bea126b55500675f4d08", client.Recycling},
+		{"", "659c317a91676f249616068c", client.Garden},
+		{"", "619f87d15c9f9c016ce81494", client.Rubbish},
+		{"ES_Refuse 1100L", "", client.Rubbish},
+		{"ES_Recycling 1280L", "", client.Recycling},
+		{"ES_Food 140L", "", client.Food},
+		{"Dumpster", "", client.UndefinedRefuseType},
+		// The ID is more reliable than the name.
+		{"ES_Refuse", "5f96b455e36673006420c529", client.Food},
+	}
+	for _, test := range tests {
+		refuseType := classifier.Classify(test.name, test.typeId).Type
+		assert.Equal(t, test.want, refuseType, "%q %q", test.name, test.typeId)
+	}
+}
+
+func TestClassifierLabels(t *testing.T) {
+	classifier, _ := client.NewClassifier(client.DefaultClassifierRules()...)
+
+	label := classifier.Classify("", "5f96b4a7d1f4f500660f2cde").Label
+
+	assert.Equal(t, "Food Caddy (Large)", label)
+}
+
+func TestClassifierRuleKinds(t *testing.T) {
+	classifier, err := client.NewClassifier(
+		client.ClassifierRule{Id: "abc", Type: client.Food},
+		client.ClassifierRule{Name: "Blue box", Type: client.Recycling},
+		client.ClassifierRule{Pattern: "(?i)garden", Type: client.Garden},
+	)
+	assert.Nil(t, err)
+
+	byId := classifier.Classify("", "abc").Type
+	byName := classifier.Classify("Blue box", "").Type
+	notWholeName := classifier.Classify("Blue box 2", "").Type
+	byPattern := classifier.Classify("Big GARDEN sack", "").Type
+
+	assert.Equal(t, client.Food, byId)
+	assert.Equal(t, client.Recycling, byName)
+	assert.Equal(t, client.UndefinedRefuseType, notWholeName)
+	assert.Equal(t, client.Garden, byPattern)
+}
+
+func TestClassifierPriorities(t *testing.T) {
+	classifier, _ := client.NewClassifier(
+		client.ClassifierRule{Pattern: "sack", Type: client.Rubbish, Label: "first"},
+		client.ClassifierRule{Pattern: "sack", Type: client.Recycling, Label: "second"},
+		client.ClassifierRule{Name: "Blue sack", Type: client.Recycling, Priority: 10},
+	)
+
+	tie := classifier.Classify("Black sack", "")
+	higher := classifier.Classify("Blue sack", "").Type
+
+	assert.Equal(t, client.Rubbish, tie.Type)
+	assert.Equal(t, "first", tie.Label)
+	assert.Equal(t, client.Recycling, higher)
+}
+
+func TestBadClassifierRules(t *testing.T) {
+	tests := []client.ClassifierRule{
+		{Type: client.Food},
+		{Id: "abc", Name: "Blue box", Type: client.Food},
+		{Id: "abc"},
+		{Pattern: "(", Type: client.Food},
+	}
+	for _, rule := range tests {
+		_, err := client.NewClassifier(rule)
+		assert.NotNil(t, err, "%+v", rule)
+	}
+}
+
+func TestReadClassifierRules(t *testing.T) {
+	rules, err := client.ReadClassifierRules(strings.NewReader(`
+		{
+			"rules": [
+				{"name": "Blue box", "type": "recycling", "label": "Recycling box", "priority": 5}
+			]
+		}
+	`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []client.ClassifierRule{
+		{Name: "Blue box", Type: client.Recycling, Label: "Recycling box", Priority: 5},
+	}, rules)
+}
+
+func TestReadClassifierRulesUnknownType(t *testing.T) {
+	_, err := client.ReadClassifierRules(strings.NewReader(`{"rules": [{"name": "Skip", "type": "rubble"}]}`))
+
+	assert.ErrorContains(t, err, "Unknown type of refuse")
+}
+
+func TestReadClassifierRulesUnknownField(t *testing.T) {
+	_, err := client.ReadClassifierRules(strings.NewReader(`{"rules": [{"nmae": "Skip", "type": "rubbish"}]}`))
+
+	assert.NotNil(t, err)
+}
+
+func TestClassifyCachedBinTypesWithCurrentRules(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"subTitle": "Dumpster", "binType": "abc"}`)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	cache, _ := client.NewMemoryCache(1024, clock)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, Cache: cache}
+
+	before, _ := binsClient.GetBinType(BinId)
+	binsClient.Classifier, _ = client.NewClassifier(client.ClassifierRule{Id: "abc", Type: client.Rubbish, Label: "Skip"})
+	after, _ := binsClient.GetBinType(BinId)
+
+	assert.Equal(t, client.UndefinedRefuseType, before.Type)
+	assert.Equal(t, client.Rubbish, after.Type)
+	assert.Equal(t, "Skip", after.Label)
+}
+
+func TestDefaultClassifierFlags(t *testing.T) {
+	classifier, _ := client.NewClassifier(client.DefaultClassifierRules()...)
+
+	estate := classifier.Classify("ES_Refuse 1100L", "")
+	eurobin := classifier.Classify("", "619f87d15c9f9c016ce81494")
+	household := classifier.Classify("", "5f96b8fce36673006420ca1f")
+
+	assert.Equal(t, client.Classification{Type: client.Rubbish, Label: "Estate Refuse", Kind: client.CommunalContainer, Communal: true}, estate)
+	assert.True(t, eurobin.Trade)
+	assert.False(t, eurobin.Communal)
+	assert.False(t, household.Trade)
+	assert.False(t, household.Communal)
+}
+
+func TestDefaultClassifierNewCategories(t *testing.T) {
+	classifier, _ := client.NewClassifier(client.DefaultClassifierRules()...)
+
+	tests := map[string]client.RefuseType{
+		"Glass Bottle Bank":        client.Glass,
+		"ES_Glass 1280L":           client.Glass,
+		"Textile Bank":             client.Textiles,
+		"Bulky Waste Collection":   client.Bulky,
+		"Small WEEE and Batteries": client.Electricals,
+		"Glassworks Refuse Sack":   client.UndefinedRefuseType,
+		"ES_Recycling Glass 1280L": client.Recycling,
+	}
+	for name, want := range tests {
+		assert.Equal(t, want, classifier.Classify(name, "").Type, name)
+	}
+}
+
+func TestRefuseTypeText(t *testing.T) {
+	for _, refuseType := range []client.RefuseType{
+		client.UndefinedRefuseType, client.Food, client.Recycling, client.Garden, client.Rubbish,
+		client.Glass, client.Textiles, client.Bulky, client.Electricals,
+	} {
+		text, _ := refuseType.MarshalText()
+		var res client.RefuseType
+		err := res.UnmarshalText(text)
+
+		assert.Nil(t, err)
+		assert.Equal(t, refuseType, res)
+	}
+}
+
+func TestDefaultClassifierContainerKinds(t *testing.T) {
+	classifier, _ := client.NewClassifier(client.DefaultClassifierRules()...)
+
+	tests := []struct {
+		name   string
+		typeId string
+		want   client.ContainerKind
+	}{
+		{"", "5f96b4a7d1f4f500660f2cde", client.Caddy},
+		{"", "5f89be840de3b800682a1ce6", client.Sack},
+		{"", "5f96b8fce36673006420ca1f", client.WheelieBin},
+		{"ES_Recycling 1280L", "", client.CommunalContainer},
+		{"", "600ae93423debf006583d078", client.UndefinedContainerKind},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, classifier.Classify(test.name, test.typeId).Kind, "%q %q", test.name, test.typeId)
+	}
+}
+
+func TestContainerKindText(t *testing.T) {
+	for _, kind := range []client.ContainerKind{
+		client.UndefinedContainerKind, client.Sack, client.Caddy, client.WheelieBin, client.CommunalContainer,
+	} {
+		text, _ := kind.MarshalText()
+		var res client.ContainerKind
+		err := res.UnmarshalText(text)
+
+		assert.Nil(t, err)
+		assert.Equal(t, kind, res)
+	}
+}
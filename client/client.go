@@ -1,11 +1,14 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
-	"github.com/hashicorp/golang-lru/v2/expirable"
+	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/jonboulle/clockwork"
 )
 
@@ -24,5 +27,58 @@ type BinsClient struct {
 	HttpClient http.Client
 	Clock      clockwork.Clock
 	ApiHost    *url.URL
-	Cache      *expirable.LRU[string, interface{}]
+	// Nil means every call goes to the API.
+	Cache     Cache
+	CacheTTLs TTLs
+	// Keeps the last good copy of everything fetched, even after it has
+	// expired from Cache, to fall back on if the API fails.
+	StaleCache *lru.Cache[string, interface{}]
+	// How old a fallback copy can be and still be used. Zero means no limit.
+	MaxStaleness time.Duration
+	// How old a copy can be and still be returned straight away once it has
+	// expired from Cache, while a fresh one is fetched in the background.
+	// Zero means always wait for the fresh one.
+	StaleWhileRevalidate time.Duration
+	// Upper limit on each call to the Council's API, including any retries.
+	// Zero means no limit.
+	Timeout time.Duration
+	Retry   RetryPolicy
+	// Shared between copies of the client. Nil means always call the API.
+	Breaker *CircuitBreaker
+	// Shared between copies of the client. Nil means identical calls made at
+	// the same time are not combined.
+	Coalescer *Coalescer
+	// Decides what sort of refuse each bin is for. Nil means the rules built
+	// into the app.
+	Classifier *Classifier
+	// Shared between copies of the client. Nil means bins that no rule
+	// classifies are only logged.
+	UnknownBinTypes *UnknownBinTypes
+}
+
+func (c BinsClient) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.Timeout)
+}
+
+// Sends a request to the Council's API, unless it is known to be down,
+// retrying according to c.Retry.
+func (c BinsClient) do(req *http.Request) (*http.Response, error) {
+	if c.Breaker != nil {
+		if err := c.Breaker.allow(); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.retry(req)
+	if c.Breaker != nil {
+		c.Breaker.record(outcomeOf(resp, err))
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		// The caller giving up says nothing about the API, but anything else
+		// stopping the call from getting an answer does.
+		return nil, unavailable(err)
+	}
+	return resp, err
 }
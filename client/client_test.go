@@ -1,7 +1,15 @@
 package client_test
 
 import (
+	"context"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/stretchr/testify/assert"
 )
 
 const PropertyId = "property"
@@ -16,3 +24,36 @@ type fakeRoundTripper struct {
 func (frt fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	return frt.Fn(req)
 }
+
+func TestCancelledContext(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Fail(t, "should not reach server")
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.GetBinIdsContext(ctx, PropertyId)
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTimeout(t *testing.T) {
+	done := make(chan struct{})
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer apiSvr.Close()
+	defer close(done)
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Timeout: time.Millisecond * 10}
+
+	_, err := client.GetWorkflowSchedule(WorkflowId)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
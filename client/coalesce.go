@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"sync"
+)
+
+// Shares one call to the Council's API between everyone who asks for the
+// same thing while it is in flight, rather than making the same call several
+// times. The call is only abandoned once every caller waiting on it has given
+// up. Safe for concurrent use, but must not be copied after first use.
+type Coalescer struct {
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+type inflightCall struct {
+	done    chan struct{}
+	value   interface{}
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func (g *Coalescer) do(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*inflightCall)
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		// Not tied to any one caller, so the call carries on if the first of
+		// them gives up.
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &inflightCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+		go func() {
+			defer cancel()
+			call.value, call.err = fn(callCtx)
+			g.forget(key, call)
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			// Anyone who asks later needs a fresh call, not this cancelled one.
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (g *Coalescer) forget(key string, call *inflightCall) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+}
+
+// Calls fetch, sharing the call with any identical ones already in flight.
+func coalesced[T any](ctx context.Context, c BinsClient, key string, fetch func(context.Context) (T, error)) (T, error) {
+	if c.Coalescer == nil {
+		return fetch(ctx)
+	}
+	res, err := c.Coalescer.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return fetch(ctx)
+	})
+	if res == nil {
+		var zero T
+		return zero, err
+	}
+	return res.(T), err
+}
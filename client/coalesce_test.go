@@ -0,0 +1,144 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// Holds every call until release is closed, letting started know about the first one.
+func heldServer(started chan<- struct{}, release <-chan struct{}, calls *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-release:
+		}
+		fmt.Fprintf(w, binIdsJsonResponse)
+	}))
+}
+
+// Lets a test know when a caller has joined a coalesced call, which is the
+// first time anything asks when the caller's context will be done.
+type waitingContext struct {
+	context.Context
+	once    sync.Once
+	waiting chan struct{}
+}
+
+func newWaitingContext(ctx context.Context) *waitingContext {
+	return &waitingContext{Context: ctx, waiting: make(chan struct{})}
+}
+
+func (c *waitingContext) Done() <-chan struct{} {
+	c.once.Do(func() { close(c.waiting) })
+	return c.Context.Done()
+}
+
+func TestCoalesceIdenticalCalls(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	apiSvr := heldServer(started, release, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	// No cache, so callers can only get the answer by sharing the call.
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Coalescer: &client.Coalescer{}}
+
+	var wg sync.WaitGroup
+	results := make([][]string, 10)
+	errs := make([]error, 10)
+	ctxs := make([]*waitingContext, 10)
+	for i := range results {
+		ctxs[i] = newWaitingContext(context.Background())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := client.GetBinIdsContext(ctxs[i], PropertyId)
+			results[i] = res.Ids
+			errs[i] = err
+		}()
+	}
+	<-started
+	for _, ctx := range ctxs {
+		<-ctx.waiting
+	}
+	close(release)
+	wg.Wait()
+
+	for i := range results {
+		assert.Nil(t, errs[i])
+		assert.Equal(t, []string{"foo", "bar"}, results[i])
+	}
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestCoalescedCallOutlivesFirstCaller(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	apiSvr := heldServer(started, release, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Coalescer: &client.Coalescer{}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	firstErr := make(chan error)
+	go func() {
+		_, err := client.GetBinIdsContext(ctx, PropertyId)
+		firstErr <- err
+	}()
+	<-started
+	secondCtx := newWaitingContext(context.Background())
+	secondErr := make(chan error)
+	go func() {
+		_, err := client.GetBinIdsContext(secondCtx, PropertyId)
+		secondErr <- err
+	}()
+	<-secondCtx.waiting
+	cancel()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+	close(release)
+
+	assert.Nil(t, <-secondErr)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestAbandonCoalescedCallWhenEveryCallerGivesUp(t *testing.T) {
+	started := make(chan struct{})
+	abandoned := make(chan struct{})
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(abandoned)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Coalescer: &client.Coalescer{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	_, err := client.GetBinIdsContext(ctx, PropertyId)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	select {
+	case <-abandoned:
+	case <-time.After(time.Second):
+		assert.Fail(t, "call to API was not abandoned")
+	}
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// A day on the calendar, with no time or timezone, such as the day a bin is
+// collected. Appears in JSON as YYYY-MM-DD.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// Normalises days outside the month, so that 32 December is 1 January.
+func NewDate(year int, month time.Month, day int) Date {
+	return DateOf(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+}
+
+// The day t falls on in its own location. Use t.In to pick the location.
+func DateOf(t time.Time) Date {
+	year, month, day := t.Date()
+	return Date{Year: year, Month: month, Day: day}
+}
+
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("Dates must be in the format YYYY-MM-DD")
+	}
+	return DateOf(t), nil
+}
+
+// The start of the day in loc.
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+func (d Date) AddDays(days int) Date {
+	return NewDate(d.Year, d.Month, d.Day+days)
+}
+
+func (d Date) Weekday() time.Weekday {
+	return d.In(time.UTC).Weekday()
+}
+
+func (d Date) Compare(other Date) int {
+	return d.In(time.UTC).Compare(other.In(time.UTC))
+}
+
+func (d Date) Before(other Date) bool {
+	return d.Compare(other) < 0
+}
+
+func (d Date) After(other Date) bool {
+	return d.Compare(other) > 0
+}
+
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+func (d Date) String() string {
+	return d.In(time.UTC).Format(dateLayout)
+}
+
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Date) UnmarshalText(text []byte) error {
+	date, err := ParseDate(string(text))
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
@@ -0,0 +1,63 @@
+package client_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDateOfUsesLocation(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	instant := time.Date(2024, 7, 1, 23, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, client.NewDate(2024, 7, 1), client.DateOf(instant))
+	assert.Equal(t, client.NewDate(2024, 7, 2), client.DateOf(instant.In(london)))
+}
+
+func TestNewDateNormalises(t *testing.T) {
+	assert.Equal(t, client.Date{Year: 2024, Month: time.January, Day: 1}, client.NewDate(2023, 12, 32))
+	assert.Equal(t, client.NewDate(2024, 3, 1), client.NewDate(2024, 2, 29).AddDays(1))
+}
+
+func TestCompareDates(t *testing.T) {
+	a := client.NewDate(2023, 12, 31)
+	b := client.NewDate(2024, 1, 1)
+
+	assert.True(t, a.Before(b))
+	assert.False(t, b.Before(a))
+	assert.True(t, b.After(a))
+	assert.Equal(t, 0, a.Compare(a))
+	assert.True(t, client.Date{}.IsZero())
+	assert.False(t, a.IsZero())
+}
+
+func TestDateIn(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+
+	res := client.NewDate(2024, 7, 1).In(london)
+
+	assert.Equal(t, "2024-07-01T00:00:00+01:00", res.Format(time.RFC3339))
+}
+
+func TestDateJson(t *testing.T) {
+	date := client.NewDate(2024, 1, 5)
+
+	data, err := json.Marshal(date)
+	assert.Nil(t, err)
+	assert.Equal(t, `"2024-01-05"`, string(data))
+
+	var res client.Date
+	assert.Nil(t, json.Unmarshal(data, &res))
+	assert.Equal(t, date, res)
+}
+
+func TestParseBadDate(t *testing.T) {
+	_, err := client.ParseDate("05/01/2024")
+	assert.NotNil(t, err)
+
+	var res client.Date
+	assert.NotNil(t, json.Unmarshal([]byte(`"2024-13-01"`), &res))
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// The kinds of error returned by the client. Use errors.Is to tell which kind
+// an error is, and errors.As with ErrUpstreamSchema, StatusError or
+// CircuitOpenError for the details.
+var (
+	// What was asked for doesn't exist, e.g. a property with no bins.
+	ErrNotFound = errors.New("Not found")
+	// The caller's input can never work, e.g. a postcode outside Hackney.
+	ErrInvalidInput = errors.New("Invalid input")
+	// The Council's API is down, failing or not answering in time.
+	ErrUpstreamUnavailable = errors.New("Council API is unavailable")
+	// The Council's API wants us to make fewer calls.
+	ErrRateLimited = errors.New("Council API is rate limiting calls")
+)
+
+// An error of one of the kinds above.
+type kindError struct {
+	kind error
+	msg  string
+	err  error
+}
+
+func (e *kindError) Error() string {
+	if e.err == nil {
+		return e.msg
+	}
+	return e.msg + ": " + e.err.Error()
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+func notFound(msg string) error {
+	return &kindError{kind: ErrNotFound, msg: msg}
+}
+
+func invalidInput(msg string) error {
+	return &kindError{kind: ErrInvalidInput, msg: msg}
+}
+
+func unavailable(err error) error {
+	return &kindError{kind: ErrUpstreamUnavailable, msg: "Could not reach the Council's API", err: err}
+}
+
+// The Council's API answered with a status code we didn't expect.
+type StatusError struct {
+	StatusCode int
+	// What was being fetched, e.g. "list of bins".
+	Fetching string
+	// How long the API asked us to wait before calling again, if it said.
+	RetryAfter time.Duration
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("Status code %v fetching %v", e.StatusCode, e.Fetching)
+}
+
+// Too Many Requests means we are rate limited and any server error means the
+// API is unavailable. Other codes don't fit any kind.
+func (e StatusError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrUpstreamUnavailable:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+func (c BinsClient) statusError(resp *http.Response, fetching string) error {
+	err := StatusError{StatusCode: resp.StatusCode, Fetching: fetching}
+	if after, ok := retryAfter(resp.Header.Get("Retry-After"), c.now()); ok {
+		err.RetryAfter = after
+	}
+	return err
+}
+
+// The Council's API sent a response we couldn't make sense of, most likely
+// because they have changed its format.
+type ErrUpstreamSchema struct {
+	// Which of the Council's endpoints sent it.
+	Endpoint string
+	// The field that was missing or had the wrong type, if known.
+	Field string
+	// Why the response couldn't be decoded, if it couldn't.
+	Err error
+}
+
+func (e ErrUpstreamSchema) Error() string {
+	msg := "Unexpected response from " + e.Endpoint
+	if e.Field != "" {
+		msg += fmt.Sprintf(" (field %v)", e.Field)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e ErrUpstreamSchema) Unwrap() error {
+	return e.Err
+}
+
+// Decodes a JSON response from endpoint into v.
+func decodeResponse(endpoint string, body []byte, v interface{}) error {
+	err := json.Unmarshal(body, v)
+	if err == nil {
+		return nil
+	}
+	schemaErr := ErrUpstreamSchema{Endpoint: endpoint, Err: err}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		schemaErr.Field = typeErr.Field
+	}
+	return schemaErr
+}
+
+func missingField(endpoint string, field string) error {
+	return ErrUpstreamSchema{Endpoint: endpoint, Field: field, Err: errors.New("Missing")}
+}
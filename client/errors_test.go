@@ -0,0 +1,170 @@
+package client_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func clientReturning(t *testing.T, body string) client.BinsClient {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(apiSvr.Close)
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	return client.BinsClient{HttpClient: http.Client{}, Clock: clockwork.NewFakeClock(), ApiHost: apiUrl}
+}
+
+func TestMissingFields(t *testing.T) {
+	tests := []struct {
+		body     string
+		get      func(client.BinsClient) error
+		endpoint string
+		field    string
+	}{
+		{
+			body:     `{"addressSummary": "foo"}`,
+			get:      func(c client.BinsClient) error { _, err := c.GetBinIds(PropertyId); return err },
+			endpoint: "/alloywastepages/getproperty/",
+			field:    "providerSpecificFields.attributes_wasteContainersAssignableWasteContainers",
+		},
+		{
+			body:     `{"providerSpecificFields": {"attributes_wasteContainersAssignableWasteContainers": "foo"}}`,
+			get:      func(c client.BinsClient) error { _, err := c.GetBinIds(PropertyId); return err },
+			endpoint: "/alloywastepages/getproperty/",
+			field:    "addressSummary",
+		},
+		{
+			body:     `{"title": "foo"}`,
+			get:      func(c client.BinsClient) error { _, err := c.GetBinType(BinId); return err },
+			endpoint: "/alloywastepages/getbin/",
+			field:    "subTitle",
+		},
+		{
+			body:     `{"workflowIDs": ["foo"]}`,
+			get:      func(c client.BinsClient) error { _, err := c.GetBinWorkflowIds(BinId); return err },
+			endpoint: "/alloywastepages/getcollection/",
+			field:    "scheduleCodeWorkflowIDs",
+		},
+		{
+			body:     `{"trigger": {}}`,
+			get:      func(c client.BinsClient) error { _, err := c.GetWorkflowSchedule(WorkflowId); return err },
+			endpoint: "/alloywastepages/getworkflow/",
+			field:    "trigger.dates",
+		},
+		{
+			body:     `{"results": []}`,
+			get:      func(c client.BinsClient) error { _, err := c.GetAddresses(Postcode); return err },
+			endpoint: "/property/opensearch",
+			field:    "addressSummaries",
+		},
+	}
+	for _, test := range tests {
+		err := test.get(clientReturning(t, test.body))
+
+		var schemaErr client.ErrUpstreamSchema
+		assert.ErrorAs(t, err, &schemaErr, test.body)
+		assert.Equal(t, test.endpoint, schemaErr.Endpoint, test.body)
+		assert.Equal(t, test.field, schemaErr.Field, test.body)
+		assert.Contains(t, err.Error(), test.field, test.body)
+	}
+}
+
+func TestFieldOfWrongType(t *testing.T) {
+	c := clientReturning(t, `{"trigger": {"dates": [42]}}`)
+
+	_, err := c.GetWorkflowSchedule(WorkflowId)
+
+	var schemaErr client.ErrUpstreamSchema
+	assert.ErrorAs(t, err, &schemaErr)
+	assert.Equal(t, "/alloywastepages/getworkflow/", schemaErr.Endpoint)
+	assert.Contains(t, schemaErr.Field, "trigger.dates")
+}
+
+func TestResponseNotJson(t *testing.T) {
+	c := clientReturning(t, `<html>Down for maintenance</html>`)
+
+	_, err := c.GetBinIds(PropertyId)
+
+	var schemaErr client.ErrUpstreamSchema
+	assert.ErrorAs(t, err, &schemaErr)
+	assert.Empty(t, schemaErr.Field)
+	assert.NotNil(t, schemaErr.Err)
+	assert.Contains(t, err.Error(), "Unexpected response from /alloywastepages/getproperty/")
+}
+
+func clientWithStatus(t *testing.T, status int, header http.Header) client.BinsClient {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		http.Error(w, "nope", status)
+	}))
+	t.Cleanup(apiSvr.Close)
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	return client.BinsClient{HttpClient: http.Client{}, Clock: clockwork.NewFakeClock(), ApiHost: apiUrl}
+}
+
+func TestRateLimited(t *testing.T) {
+	c := clientWithStatus(t, http.StatusTooManyRequests, http.Header{"Retry-After": {"30"}})
+
+	_, err := c.GetBinType(BinId)
+
+	var statusErr client.StatusError
+	assert.ErrorIs(t, err, client.ErrRateLimited)
+	assert.NotErrorIs(t, err, client.ErrUpstreamUnavailable)
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusTooManyRequests, statusErr.StatusCode)
+	assert.Equal(t, time.Second*30, statusErr.RetryAfter)
+	assert.Equal(t, "Status code 429 fetching types of bins", err.Error())
+}
+
+func TestServerErrorIsUnavailable(t *testing.T) {
+	c := clientWithStatus(t, http.StatusBadGateway, nil)
+
+	_, err := c.GetBinWorkflowIds(BinId)
+
+	assert.ErrorIs(t, err, client.ErrUpstreamUnavailable)
+	assert.Equal(t, "Status code 502 fetching workflows of bins", err.Error())
+}
+
+func TestOtherStatusHasNoKind(t *testing.T) {
+	c := clientWithStatus(t, http.StatusTeapot, nil)
+
+	_, err := c.GetWorkflowSchedule(WorkflowId)
+
+	assert.ErrorAs(t, err, &client.StatusError{})
+	for _, kind := range []error{client.ErrNotFound, client.ErrInvalidInput, client.ErrUpstreamUnavailable, client.ErrRateLimited} {
+		assert.NotErrorIs(t, err, kind)
+	}
+}
+
+func TestUnreachableApiIsUnavailable(t *testing.T) {
+	apiSvr := httptest.NewServer(http.NotFoundHandler())
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	apiSvr.Close()
+	c := client.BinsClient{HttpClient: http.Client{}, Clock: clockwork.NewFakeClock(), ApiHost: apiUrl}
+
+	_, err := c.GetBinIds(PropertyId)
+
+	assert.ErrorIs(t, err, client.ErrUpstreamUnavailable)
+	assert.Contains(t, err.Error(), "Could not reach the Council's API")
+}
+
+func TestCircuitOpenIsUnavailable(t *testing.T) {
+	assert.ErrorIs(t, client.CircuitOpenError{}, client.ErrUpstreamUnavailable)
+}
+
+func TestInvalidInputErrors(t *testing.T) {
+	for _, err := range []error{client.ErrBadPropertyId, client.NotHackneyErr, client.InvalidPostcodeErr} {
+		assert.ErrorIs(t, err, client.ErrInvalidInput)
+		assert.NotErrorIs(t, err, client.ErrNotFound)
+	}
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+func init() {
+	// Everything a Namespace can hold, so it can be written to disk.
+	gob.Register([]Address{})
+	gob.Register(BinIds{})
+	gob.Register(BinType{})
+	gob.Register([]string{})
+	gob.Register([]time.Time{})
+}
+
+type fileEntry struct {
+	Namespace string
+	Key       string
+	Value     interface{}
+	Expires   time.Time
+}
+
+// A MemoryCache that can be saved to a file and loaded again when the service
+// restarts, so that it doesn't start out empty. Only entries in the chosen
+// namespaces are saved. Safe for concurrent use.
+type FileCache struct {
+	*MemoryCache
+	path       string
+	namespaces []string
+	saving     sync.Mutex
+}
+
+func NewFileCache(path string, size int, clock clockwork.Clock, namespaces ...string) (*FileCache, error) {
+	m, err := NewMemoryCache(size, clock)
+	if err != nil {
+		return nil, err
+	}
+	return &FileCache{MemoryCache: m, path: path, namespaces: namespaces}, nil
+}
+
+// Adds whatever was last saved to the file, leaving out anything that has
+// expired since. It is not an error for the file not to exist yet.
+func (f *FileCache) Load() error {
+	file, err := os.Open(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var saved []fileEntry
+	if err := gob.NewDecoder(file).Decode(&saved); err != nil {
+		return err
+	}
+	for _, e := range saved {
+		entry := memoryEntry{value: e.Value, expires: e.Expires}
+		if !slices.Contains(f.namespaces, e.Namespace) || f.expired(entry) {
+			continue
+		}
+		f.entries.Add(memoryKey(e.Namespace, e.Key), entry)
+	}
+	return nil
+}
+
+// Writes every unexpired entry in the chosen namespaces to the file. The old
+// copy is only replaced once the new one has been written in full.
+func (f *FileCache) Save() error {
+	f.saving.Lock()
+	defer f.saving.Unlock()
+
+	var entries []fileEntry
+	// Oldest first, so they are loaded back in the same order.
+	for _, k := range f.entries.Keys() {
+		entry, found := f.entries.Peek(k)
+		if !found || f.expired(entry) {
+			continue
+		}
+		namespace, key, _ := strings.Cut(k, " ")
+		if !slices.Contains(f.namespaces, namespace) {
+			continue
+		}
+		entries = append(entries, fileEntry{
+			Namespace: namespace,
+			Key:       key,
+			Value:     entry.value,
+			Expires:   entry.expires,
+		})
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := gob.NewEncoder(tmp).Encode(entries); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,111 @@
+package client_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+var savedNamespaces = []string{
+	client.CachedBinTypes.Name,
+	client.CachedWorkflowIds.Name,
+	client.CachedSchedules.Name,
+}
+
+func TestFileCacheRestoresSavedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache")
+	clock := clockwork.NewFakeClock()
+	ttls := client.TTLs{BinTypes: time.Hour, WorkflowIds: time.Hour, Schedules: time.Hour}
+	binType := client.BinType{Name: "Garbage can", Type: client.Garden}
+	schedule := []time.Time{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	before, _ := client.NewFileCache(path, 1024, clock, savedNamespaces...)
+	client.CachedBinTypes.Add(before, ttls, "bin", binType)
+	client.CachedWorkflowIds.Add(before, ttls, "bin", []string{"foo", "bar"})
+	client.CachedSchedules.Add(before, ttls, "workflow", schedule)
+
+	assert.Nil(t, before.Save())
+	after, _ := client.NewFileCache(path, 1024, clock, savedNamespaces...)
+	assert.Nil(t, after.Load())
+
+	resBinType, found := client.CachedBinTypes.Get(after, "bin")
+	assert.True(t, found)
+	assert.Equal(t, binType, resBinType)
+	resWorkflowIds, found := client.CachedWorkflowIds.Get(after, "bin")
+	assert.True(t, found)
+	assert.Equal(t, []string{"foo", "bar"}, resWorkflowIds)
+	resSchedule, found := client.CachedSchedules.Get(after, "workflow")
+	assert.True(t, found)
+	assert.True(t, schedule[0].Equal(resSchedule[0]))
+}
+
+func TestFileCacheOnlySavesChosenNamespaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache")
+	before, _ := client.NewFileCache(path, 1024, nil, savedNamespaces...)
+	client.CachedResponses.Add(before, client.TTLs{}, "key", "foo")
+
+	assert.Nil(t, before.Save())
+	after, _ := client.NewFileCache(path, 1024, nil, savedNamespaces...)
+	assert.Nil(t, after.Load())
+
+	_, found := client.CachedResponses.Get(after, "key")
+	assert.False(t, found)
+	assert.Equal(t, 0, after.Len())
+}
+
+func TestFileCacheLeavesOutExpiredEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache")
+	clock := clockwork.NewFakeClock()
+	ttls := client.TTLs{BinTypes: time.Hour * 24, Schedules: time.Hour}
+	before, _ := client.NewFileCache(path, 1024, clock, savedNamespaces...)
+	client.CachedBinTypes.Add(before, ttls, "bin", client.BinType{Name: "foo"})
+	client.CachedSchedules.Add(before, ttls, "workflow", []time.Time{clock.Now()})
+
+	assert.Nil(t, before.Save())
+	clock.Advance(time.Hour * 2)
+	after, _ := client.NewFileCache(path, 1024, clock, savedNamespaces...)
+	assert.Nil(t, after.Load())
+
+	_, found := client.CachedBinTypes.Get(after, "bin")
+	assert.True(t, found)
+	_, found = client.CachedSchedules.Get(after, "workflow")
+	assert.False(t, found)
+	assert.Equal(t, 1, after.Len())
+}
+
+func TestFileCacheKeepsExpiryTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache")
+	clock := clockwork.NewFakeClock()
+	ttls := client.TTLs{BinTypes: time.Hour}
+	before, _ := client.NewFileCache(path, 1024, clock, savedNamespaces...)
+	client.CachedBinTypes.Add(before, ttls, "bin", client.BinType{Name: "foo"})
+
+	assert.Nil(t, before.Save())
+	after, _ := client.NewFileCache(path, 1024, clock, savedNamespaces...)
+	assert.Nil(t, after.Load())
+	clock.Advance(time.Hour)
+
+	_, found := client.CachedBinTypes.Get(after, "bin")
+	assert.False(t, found)
+}
+
+func TestFileCacheWithoutFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache")
+	cache, _ := client.NewFileCache(path, 1024, nil, savedNamespaces...)
+
+	assert.Nil(t, cache.Load())
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestFileCacheCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache")
+	os.WriteFile(path, []byte("not a cache"), 0644)
+	cache, _ := client.NewFileCache(path, 1024, nil, savedNamespaces...)
+
+	assert.NotNil(t, cache.Load())
+	assert.Equal(t, 0, cache.Len())
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/jonboulle/clockwork"
+)
+
+type memoryEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// A Cache that keeps up to a fixed number of entries in memory, evicting the
+// least recently used first. Safe for concurrent use.
+type MemoryCache struct {
+	clock   clockwork.Clock
+	entries *lru.Cache[string, memoryEntry]
+}
+
+// Nil clock means the real clock.
+func NewMemoryCache(size int, clock clockwork.Clock) (*MemoryCache, error) {
+	entries, err := lru.New[string, memoryEntry](size)
+	if err != nil {
+		return nil, err
+	}
+	if clock == nil {
+		clock = clockwork.NewRealClock()
+	}
+	return &MemoryCache{clock: clock, entries: entries}, nil
+}
+
+func memoryKey(namespace, key string) string {
+	return namespace + " " + key
+}
+
+func (m *MemoryCache) Get(namespace, key string) (interface{}, bool) {
+	k := memoryKey(namespace, key)
+	entry, found := m.entries.Get(k)
+	if !found {
+		return nil, false
+	}
+	if m.expired(entry) {
+		m.entries.Remove(k)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (m *MemoryCache) Add(namespace, key string, value interface{}, ttl time.Duration) {
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expires = m.clock.Now().Add(ttl)
+	}
+	m.entries.Add(memoryKey(namespace, key), entry)
+}
+
+func (m *MemoryCache) expired(entry memoryEntry) bool {
+	return !entry.expires.IsZero() && !m.clock.Now().Before(entry.expires)
+}
+
+// How many entries are held, including any that have expired but not yet
+// been removed.
+func (m *MemoryCache) Len() int {
+	return m.entries.Len()
+}
@@ -0,0 +1,70 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCacheExpiresEachNamespaceSeparately(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	cache, _ := client.NewMemoryCache(1024, clock)
+	ttls := client.TTLs{BinTypes: time.Hour * 24, Schedules: time.Hour}
+	client.CachedBinTypes.Add(cache, ttls, "key", client.BinType{Name: "foo"})
+	client.CachedSchedules.Add(cache, ttls, "key", []time.Time{clock.Now()})
+
+	clock.Advance(time.Hour)
+	binType, binTypeFound := client.CachedBinTypes.Get(cache, "key")
+	_, scheduleFound := client.CachedSchedules.Get(cache, "key")
+
+	assert.True(t, binTypeFound)
+	assert.Equal(t, "foo", binType.Name)
+	assert.False(t, scheduleFound)
+}
+
+func TestMemoryCacheKeepsEntriesWithoutTtl(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	cache, _ := client.NewMemoryCache(1024, clock)
+	client.CachedResponses.Add(cache, client.TTLs{}, "key", "foo")
+
+	clock.Advance(time.Hour * 24 * 365)
+	res, found := client.CachedResponses.Get(cache, "key")
+
+	assert.True(t, found)
+	assert.Equal(t, "foo", res)
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, _ := client.NewMemoryCache(2, nil)
+	client.CachedResponses.Add(cache, client.TTLs{}, "a", "foo")
+	client.CachedResponses.Add(cache, client.TTLs{}, "b", "bar")
+	client.CachedResponses.Get(cache, "a")
+	client.CachedResponses.Add(cache, client.TTLs{}, "c", "baz")
+
+	_, aFound := client.CachedResponses.Get(cache, "a")
+	_, bFound := client.CachedResponses.Get(cache, "b")
+
+	assert.True(t, aFound)
+	assert.False(t, bFound)
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestNamespaceMissesValueOfWrongType(t *testing.T) {
+	cache, _ := client.NewMemoryCache(1024, nil)
+	cache.Add(client.CachedResponses.Name, "key", 42, 0)
+
+	_, found := client.CachedResponses.Get(cache, "key")
+
+	assert.False(t, found)
+}
+
+func TestNamespaceWithoutCache(t *testing.T) {
+	client.CachedResponses.Add(nil, client.TTLs{}, "key", "foo")
+
+	_, found := client.CachedResponses.Get(nil, "key")
+
+	assert.False(t, found)
+}
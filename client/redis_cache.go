@@ -0,0 +1,268 @@
+package client
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const redisPoolSize = 8
+
+// A Cache kept in Redis, or anything else that speaks its protocol, so that
+// several copies of the service can share it. Entries are stored as JSON.
+// If Redis can't be reached, every lookup misses and nothing is stored.
+// Safe for concurrent use.
+type RedisCache struct {
+	addr     string
+	useTLS   bool
+	username string
+	password string
+	db       int
+	idle     chan *redisConn
+
+	// Put in front of every key, so the database can be shared with other
+	// services. Defaults to "hackney-bindicator:".
+	Prefix string
+	// Upper limit on each command. Zero means no limit.
+	Timeout time.Duration
+	// Called with every error talking to Redis, since callers never see them.
+	// Nil means they are ignored.
+	OnError func(error)
+}
+
+// Connects lazily to the server at rawURL, which looks like
+// redis://[user:password@]host:port[/db], or rediss:// to use TLS.
+func NewRedisCache(rawURL string) (*RedisCache, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("Redis URL must start with redis:// or rediss://")
+	}
+	c := &RedisCache{
+		addr:   u.Host,
+		useTLS: u.Scheme == "rediss",
+		idle:   make(chan *redisConn, redisPoolSize),
+		Prefix: "hackney-bindicator:",
+	}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		c.db, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("Redis database must be a number, not %v", db)
+		}
+	}
+	return c, nil
+}
+
+func (c *RedisCache) key(namespace, key string) string {
+	return c.Prefix + namespace + ":" + key
+}
+
+func (c *RedisCache) Get(namespace, key string) (interface{}, bool) {
+	reply, err := c.do("GET", c.key(namespace, key))
+	if err != nil {
+		c.report(err)
+		return nil, false
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		// Nothing stored
+		return nil, false
+	}
+	value, err := decode(namespace, data)
+	if err != nil {
+		c.report(err)
+		return nil, false
+	}
+	return value, true
+}
+
+func (c *RedisCache) Add(namespace, key string, value interface{}, ttl time.Duration) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		c.report(err)
+		return
+	}
+	args := []string{"SET", c.key(namespace, key), string(data)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	if _, err := c.do(args...); err != nil {
+		c.report(err)
+	}
+}
+
+func (c *RedisCache) report(err error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+// Closes any idle connections.
+func (c *RedisCache) Close() {
+	for {
+		select {
+		case conn := <-c.idle:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// An error reply from Redis. The connection is still usable after one.
+type redisError string
+
+func (e redisError) Error() string {
+	return "Redis: " + string(e)
+}
+
+// Sends one command and returns the reply.
+func (c *RedisCache) do(args ...string) (interface{}, error) {
+	conn, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(c.Timeout, args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// Can't tell what state the connection is in, so start again.
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case c.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+func (c *RedisCache) conn() (*redisConn, error) {
+	select {
+	case conn := <-c.idle:
+		return conn, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: c.Timeout}
+	var netConn net.Conn
+	var err error
+	if c.useTLS {
+		host, _, _ := net.SplitHostPort(c.addr)
+		netConn, err = tls.DialWithDialer(dialer, "tcp", c.addr, &tls.Config{ServerName: host})
+	} else {
+		netConn, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: netConn, r: bufio.NewReader(netConn)}
+
+	if c.password != "" {
+		args := []string{"AUTH", c.password}
+		if c.username != "" {
+			args = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := conn.do(c.Timeout, args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := conn.do(c.Timeout, "SELECT", strconv.Itoa(c.db)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (conn *redisConn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	} else {
+		conn.SetDeadline(time.Time{})
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return nil, err
+	}
+	return readRedisReply(conn.r)
+}
+
+// Reads one reply in the Redis serialization protocol. Bulk strings come back
+// as []byte, and nil means there was nothing there.
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("Malformed reply from Redis: %q", line)
+	}
+	kind, rest := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return rest, nil
+	case '-':
+		return nil, redisError(rest)
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed reply from Redis: %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(rest)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed reply from Redis: %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			items[i], err = readRedisReply(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("Malformed reply from Redis: %q", line)
+}
@@ -0,0 +1,293 @@
+package client_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// Just enough of a Redis server to test against. Records every command.
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	values   map[string]string
+	commands [][]string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	f := &fakeRedis{listener: listener, password: password, values: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+func (f *fakeRedis) url() string {
+	return "redis://" + f.listener.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		switch {
+		case strings.ToUpper(args[0]) == "AUTH":
+			authed = args[len(args)-1] == f.password
+			if authed {
+				io.WriteString(conn, "+OK\r\n")
+			} else {
+				io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+			}
+		case !authed:
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+		case strings.ToUpper(args[0]) == "GET":
+			if value, ok := f.values[args[1]]; ok {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+			} else {
+				io.WriteString(conn, "$-1\r\n")
+			}
+		case strings.ToUpper(args[0]) == "SET":
+			f.values[args[1]] = args[2]
+			io.WriteString(conn, "+OK\r\n")
+		case strings.ToUpper(args[0]) == "SELECT":
+			io.WriteString(conn, "+OK\r\n")
+		default:
+			io.WriteString(conn, "-ERR unknown command\r\n")
+		}
+		f.mu.Unlock()
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) lastCommand() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.commands[len(f.commands)-1]
+}
+
+func TestRedisCacheRoundTrip(t *testing.T) {
+	server := newFakeRedis(t, "")
+	cache, _ := client.NewRedisCache(server.url())
+	defer cache.Close()
+	ttls := client.TTLs{}
+	binIds := client.BinIds{Name: "29 ACACIA AVENUE", Ids: []string{"foo", "bar"}}
+	binType := client.BinType{Name: "Garbage can", Type: client.Garden}
+	addresses := []client.Address{{Id: "foo", Name: "29 ACACIA AVENUE"}}
+	schedule := []time.Time{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	client.CachedBinIds.Add(cache, ttls, "key", binIds)
+	client.CachedBinTypes.Add(cache, ttls, "key", binType)
+	client.CachedAddresses.Add(cache, ttls, "key", addresses)
+	client.CachedSchedules.Add(cache, ttls, "key", schedule)
+	client.CachedWorkflowIds.Add(cache, ttls, "key", []string{"foo"})
+	client.CachedResponses.Add(cache, ttls, "key", "response")
+
+	resBinIds, found := client.CachedBinIds.Get(cache, "key")
+	assert.True(t, found)
+	assert.Equal(t, binIds, resBinIds)
+	resBinType, found := client.CachedBinTypes.Get(cache, "key")
+	assert.True(t, found)
+	assert.Equal(t, binType, resBinType)
+	resAddresses, found := client.CachedAddresses.Get(cache, "key")
+	assert.True(t, found)
+	assert.Equal(t, addresses, resAddresses)
+	resSchedule, found := client.CachedSchedules.Get(cache, "key")
+	assert.True(t, found)
+	assert.True(t, schedule[0].Equal(resSchedule[0]))
+	resWorkflowIds, found := client.CachedWorkflowIds.Get(cache, "key")
+	assert.True(t, found)
+	assert.Equal(t, []string{"foo"}, resWorkflowIds)
+	resResponse, found := client.CachedResponses.Get(cache, "key")
+	assert.True(t, found)
+	assert.Equal(t, "response", resResponse)
+}
+
+func TestRedisCacheMiss(t *testing.T) {
+	server := newFakeRedis(t, "")
+	cache, _ := client.NewRedisCache(server.url())
+	defer cache.Close()
+
+	_, found := client.CachedBinTypes.Get(cache, "key")
+
+	assert.False(t, found)
+}
+
+func TestRedisCacheSetsExpiry(t *testing.T) {
+	server := newFakeRedis(t, "")
+	cache, _ := client.NewRedisCache(server.url())
+	defer cache.Close()
+
+	client.CachedSchedules.Add(cache, client.TTLs{Schedules: time.Minute}, "key", []time.Time{})
+
+	assert.Equal(t, []string{"SET", "hackney-bindicator:schedules:key", "[]", "PX", "60000"}, server.lastCommand())
+}
+
+func TestRedisCacheNoExpiryWithoutTtl(t *testing.T) {
+	server := newFakeRedis(t, "")
+	cache, _ := client.NewRedisCache(server.url())
+	defer cache.Close()
+	cache.Prefix = "test:"
+
+	client.CachedResponses.Add(cache, client.TTLs{}, "key", "foo")
+
+	assert.Equal(t, []string{"SET", "test:responses:key", `"foo"`}, server.lastCommand())
+}
+
+func TestRedisCacheKeepsNamespacesApart(t *testing.T) {
+	server := newFakeRedis(t, "")
+	cache, _ := client.NewRedisCache(server.url())
+	defer cache.Close()
+
+	client.CachedResponses.Add(cache, client.TTLs{}, "key", "foo")
+	_, found := client.CachedBinTypes.Get(cache, "key")
+
+	assert.False(t, found)
+}
+
+func TestRedisCacheAuthenticates(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	cache, _ := client.NewRedisCache("redis://:secret@" + server.listener.Addr().String() + "/2")
+	defer cache.Close()
+
+	client.CachedResponses.Add(cache, client.TTLs{}, "key", "foo")
+	res, found := client.CachedResponses.Get(cache, "key")
+
+	assert.True(t, found)
+	assert.Equal(t, "foo", res)
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, []string{"AUTH", "secret"}, server.commands[0])
+	assert.Equal(t, []string{"SELECT", "2"}, server.commands[1])
+}
+
+func TestRedisCacheWrongPassword(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	cache, _ := client.NewRedisCache("redis://:wrong@" + server.listener.Addr().String())
+	defer cache.Close()
+	var errs []error
+	cache.OnError = func(err error) { errs = append(errs, err) }
+
+	client.CachedResponses.Add(cache, client.TTLs{}, "key", "foo")
+	_, found := client.CachedResponses.Get(cache, "key")
+
+	assert.False(t, found)
+	assert.Len(t, errs, 2)
+	assert.Contains(t, errs[0].Error(), "WRONGPASS")
+}
+
+func TestRedisCacheUnreachable(t *testing.T) {
+	server := newFakeRedis(t, "")
+	server.listener.Close()
+	cache, _ := client.NewRedisCache(server.url())
+	defer cache.Close()
+	cache.Timeout = time.Second
+	var errs []error
+	cache.OnError = func(err error) { errs = append(errs, err) }
+
+	client.CachedResponses.Add(cache, client.TTLs{}, "key", "foo")
+	_, found := client.CachedResponses.Get(cache, "key")
+
+	assert.False(t, found)
+	assert.Len(t, errs, 2)
+}
+
+func TestRedisCacheUndecodableValue(t *testing.T) {
+	server := newFakeRedis(t, "")
+	server.values["hackney-bindicator:bin_types:key"] = "not json"
+	cache, _ := client.NewRedisCache(server.url())
+	defer cache.Close()
+
+	_, found := client.CachedBinTypes.Get(cache, "key")
+
+	assert.False(t, found)
+}
+
+func TestRedisCacheBadUrl(t *testing.T) {
+	_, err := client.NewRedisCache("http://localhost")
+	assert.NotNil(t, err)
+	_, err = client.NewRedisCache("redis://localhost/foo")
+	assert.NotNil(t, err)
+}
+
+func TestFetchBinTypeOnceWithRedisCache(t *testing.T) {
+	fetches := 0
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `
+			{
+				"subTitle": "Garbage sack",
+				"binType": "5f96b455e36673006420c529"
+			}
+		`)
+		fetches += 1
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	server := newFakeRedis(t, "")
+	cache, _ := client.NewRedisCache(server.url())
+	defer cache.Close()
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Cache: cache}
+
+	first, _ := client.GetBinType(BinId)
+	second, err := client.GetBinType(BinId)
+
+	assert.Nil(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, fetches, 1)
+}
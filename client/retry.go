@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+var DefaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// Controls how calls to the Council's API are retried. The zero value makes
+// every call exactly once.
+type RetryPolicy struct {
+	// Most calls to make in total, including the first.
+	MaxAttempts int
+	// How long to wait before the first retry. Doubles after each one.
+	InitialBackoff time.Duration
+	// Longest to wait between calls. If the API asks us to wait longer than
+	// this using Retry-After, give up instead. Zero means no limit.
+	MaxBackoff time.Duration
+	// Fraction of each wait that is randomised, between 0 and 1, so that
+	// retries from concurrent requests are spread out.
+	Jitter float64
+	// Nil means DefaultRetryableStatusCodes. Transport errors are always retried.
+	RetryableStatusCodes []int
+}
+
+func (p RetryPolicy) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	codes := p.RetryableStatusCodes
+	if codes == nil {
+		codes = DefaultRetryableStatusCodes
+	}
+	return slices.Contains(codes, resp.StatusCode)
+}
+
+// Returns how long to wait before the given retry, and whether to make it at all.
+func (p RetryPolicy) backoff(retry int, resp *http.Response, now time.Time) (time.Duration, bool) {
+	wait := p.InitialBackoff << (retry - 1)
+	if wait < p.InitialBackoff { // Overflowed
+		wait = p.MaxBackoff
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		wait -= time.Duration(rand.Float64() * p.Jitter * float64(wait))
+	}
+
+	if resp != nil {
+		if after, ok := retryAfter(resp.Header.Get("Retry-After"), now); ok && after > wait {
+			if p.MaxBackoff > 0 && after > p.MaxBackoff {
+				return 0, false
+			}
+			wait = after
+		}
+	}
+	return wait, true
+}
+
+// The header holds either a number of seconds or a date.
+func retryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// Sends a request, trying again according to c.Retry if it fails.
+func (c BinsClient) retry(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for retry := 1; ; retry++ {
+		resp, err := c.HttpClient.Do(req)
+		if retry >= c.Retry.MaxAttempts || ctx.Err() != nil || !c.Retry.retryable(resp, err) {
+			return resp, err
+		}
+		wait, ok := c.Retry.backoff(retry, resp, c.now())
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := c.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+
+		req = req.Clone(ctx)
+		if req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (c BinsClient) now() time.Time {
+	if c.Clock == nil {
+		return time.Now()
+	}
+	return c.Clock.Now()
+}
+
+func (c BinsClient) sleep(ctx context.Context, d time.Duration) error {
+	after := time.After
+	if c.Clock != nil {
+		after = c.Clock.After
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-after(d):
+		return nil
+	}
+}
@@ -0,0 +1,229 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+const binIdsJsonResponse = `
+	{
+		"addressSummary": "29 ACACIA AVENUE",
+		"providerSpecificFields": {
+			"attributes_wasteContainersAssignableWasteContainers": "foo,bar"
+		}
+	}
+`
+
+// Fails with the given status until it has been called failures times.
+func flappingServer(failures int32, status int, calls *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			http.Error(w, "nope", status)
+			return
+		}
+		fmt.Fprintf(w, binIdsJsonResponse)
+	}))
+}
+
+var fastRetries = client.RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     time.Millisecond * 5,
+	Jitter:         0.5,
+}
+
+func TestRetryUntilSuccess(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := flappingServer(2, http.StatusServiceUnavailable, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Retry: fastRetries}
+
+	res, err := client.GetBinIds(PropertyId)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo", "bar"}, res.Ids)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := flappingServer(3, http.StatusBadGateway, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Retry: fastRetries}
+
+	_, err := client.GetBinIds(PropertyId)
+
+	assert.Contains(t, err.Error(), "Status code 502")
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestNoRetryByDefault(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := flappingServer(1, http.StatusServiceUnavailable, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
+
+	_, err := client.GetBinIds(PropertyId)
+
+	assert.Contains(t, err.Error(), "Status code 503")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestNoRetryForNonRetryableStatus(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := flappingServer(1, http.StatusBadRequest, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Retry: fastRetries}
+
+	_, err := binsClient.GetBinIds(PropertyId)
+
+	assert.Equal(t, err, client.ErrBadPropertyId)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRetryCustomStatusCodes(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := flappingServer(1, http.StatusTeapot, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	policy := fastRetries
+	policy.RetryableStatusCodes = []int{http.StatusTeapot}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Retry: policy}
+
+	_, err := client.GetBinIds(PropertyId)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestRetryTransportErrors(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := flappingServer(0, http.StatusOK, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	var failures atomic.Int32
+	httpClient := http.Client{
+		Transport: fakeRoundTripper{
+			Fn: func(req *http.Request) (*http.Response, error) {
+				if failures.Add(1) == 1 {
+					return nil, errors.New("connection reset")
+				}
+				return http.DefaultTransport.RoundTrip(req)
+			},
+		},
+	}
+	client := client.BinsClient{HttpClient: httpClient, ApiHost: apiUrl, Retry: fastRetries}
+
+	_, err := client.GetBinIds(PropertyId)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), failures.Load())
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRetryResendsRequestBody(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(body), Postcode)
+		if calls.Add(1) == 1 {
+			http.Error(w, "nope", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `{"addressSummaries": [{"systemId": "foo", "summary": "bar"}]}`)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Retry: fastRetries}
+
+	res, err := client.GetAddresses(Postcode)
+
+	assert.Nil(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "30")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprintf(w, binIdsJsonResponse)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, Retry: client.RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+	}}
+
+	errs := make(chan error)
+	go func() {
+		_, err := client.GetBinIds(PropertyId)
+		errs <- err
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, clock.BlockUntilContext(ctx, 1))
+	clock.Advance(time.Second * 29)
+	assert.Equal(t, int32(1), calls.Load())
+	clock.Advance(time.Second)
+
+	assert.Nil(t, <-errs)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestRetryAfterLongerThanMaxBackoff(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Retry: fastRetries}
+
+	_, err := client.GetBinIds(PropertyId)
+
+	assert.Contains(t, err.Error(), "Status code 503")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestStopRetryingWhenContextDone(t *testing.T) {
+	var calls atomic.Int32
+	apiSvr := flappingServer(10, http.StatusServiceUnavailable, &calls)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Retry: client.RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: time.Hour,
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*10, cancel)
+
+	_, err := client.GetBinIdsContext(ctx, PropertyId)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(1), calls.Load())
+}
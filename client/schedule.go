@@ -1,39 +1,65 @@
 package client
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"io/ioutil"
 	"net/http"
 	"time"
 )
 
-func (c BinsClient) GetWorkflowSchedule(workflowId string) ([]time.Time, error) {
-	target := c.ApiHost.JoinPath(scheduleUrl, workflowId).String()
+func (c BinsClient) GetWorkflowSchedule(workflowId string) ([]Date, error) {
+	return c.GetWorkflowScheduleContext(context.Background(), workflowId)
+}
 
-	if c.Cache != nil {
-		if res, found := c.Cache.Get(target); found {
-			return res.([]time.Time), nil
-		}
+// Returns the dates of collections from today onwards, in London. The cache
+// holds every time the API returned, so that what counts as upcoming is worked
+// out afresh each time rather than when the schedule was fetched.
+func (c BinsClient) GetWorkflowScheduleContext(ctx context.Context, workflowId string) ([]Date, error) {
+	target := c.ApiHost.JoinPath(scheduleUrl, workflowId).String()
+	dates, err := cached(ctx, c, CachedSchedules, target, func(ctx context.Context) ([]time.Time, error) {
+		return c.fetchWorkflowSchedule(ctx, target)
+	})
+	if err != nil {
+		return []Date{}, err
 	}
+	return c.upcoming(dates)
+}
 
+func (c BinsClient) upcoming(times []time.Time) ([]Date, error) {
 	london, err := time.LoadLocation("Europe/London")
 	if err != nil {
-		return []time.Time{}, err
+		return []Date{}, err
+	}
+	today := DateOf(c.Clock.Now().In(london))
+
+	var schedule []Date
+	for _, t := range times {
+		// The API gives times in UTC, which are an hour behind during BST.
+		date := DateOf(t.In(london))
+		if date.Before(today) {
+			continue
+		}
+		schedule = append(schedule, date)
 	}
-	req, err := http.NewRequest(http.MethodGet, target, nil)
+	return schedule, nil
+}
+
+func (c BinsClient) fetchWorkflowSchedule(ctx context.Context, target string) ([]time.Time, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
 	if err != nil {
 		return []time.Time{}, err
 	}
 	req.Header.Add("User-Agent", userAgent)
 	req.Header.Add("Accept", "application/json")
 
-	resp, err := c.HttpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return []time.Time{}, err
 	}
 	if resp.StatusCode != 200 {
-		return []time.Time{}, fmt.Errorf("Status code %v fetching workflow schedule", resp.StatusCode)
+		return []time.Time{}, c.statusError(resp, "workflow schedule")
 	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -41,27 +67,18 @@ func (c BinsClient) GetWorkflowSchedule(workflowId string) ([]time.Time, error)
 	}
 
 	type workflow struct {
-		Trigger struct {
-			Dates []time.Time `json:"dates"`
+		Trigger *struct {
+			Dates *[]time.Time `json:"dates"`
 		} `json:"trigger"`
 	}
 
 	var data workflow
-	json.Unmarshal(body, &data)
-
-	var schedule []time.Time
-	now := c.Clock.Now()
-	for _, t := range data.Trigger.Dates {
-		if t.Before(now) {
-			continue
-		}
-		date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, london)
-		schedule = append(schedule, date)
+	if err := decodeResponse(scheduleUrl, body, &data); err != nil {
+		return []time.Time{}, err
 	}
-
-	if c.Cache != nil {
-		c.Cache.Add(target, schedule)
+	if data.Trigger == nil || data.Trigger.Dates == nil {
+		return []time.Time{}, missingField(scheduleUrl, "trigger.dates")
 	}
 
-	return schedule, nil
+	return *data.Trigger.Dates, nil
 }
@@ -12,14 +12,13 @@ import (
 	"time"
 
 	"github.com/dinosaursrarr/hackney-bindicator/client"
-	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/jonboulle/clockwork"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestBadWorkflowScheduleUrl(t *testing.T) {
 	badUrl, _ := url.Parse("ftp://foo.bar")
-	client := client.BinsClient{http.Client{}, nil, badUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: badUrl}
 
 	res, err := client.GetWorkflowSchedule(WorkflowId)
 
@@ -34,7 +33,7 @@ func TestSetUserAgentGettingWorkflowSchedule(t *testing.T) {
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{http.Client{}, clock, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
 
 	client.GetWorkflowSchedule(WorkflowId)
 }
@@ -46,7 +45,7 @@ func TestSetAcceptGettingWorkflowSchedule(t *testing.T) {
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{http.Client{}, clock, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
 
 	client.GetWorkflowSchedule(WorkflowId)
 }
@@ -62,7 +61,7 @@ func TestHttpErrorGettingWorkflowSchedule(t *testing.T) {
 			},
 		},
 	}
-	client := client.BinsClient{httpClient, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: httpClient, ApiHost: apiUrl}
 
 	res, err := client.GetWorkflowSchedule(WorkflowId)
 
@@ -76,7 +75,7 @@ func TestBadStatusCodeGettingWorkflowSchedule(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
 	res, err := client.GetWorkflowSchedule(WorkflowId)
 
@@ -98,7 +97,7 @@ func TestErrorReadingWorkflowSchedule(t *testing.T) {
 			},
 		},
 	}
-	client := client.BinsClient{httpClient, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: httpClient, ApiHost: apiUrl}
 
 	res, err := client.GetWorkflowSchedule(WorkflowId)
 
@@ -106,17 +105,18 @@ func TestErrorReadingWorkflowSchedule(t *testing.T) {
 	assert.Contains(t, err.Error(), "nope")
 }
 
-func TestWorkflowScheduleNotFound(t *testing.T) {
+func TestWorkflowScheduleEmptyResponse(t *testing.T) {
 	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{http.Client{}, clock, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
 
-	res, err := client.GetWorkflowSchedule(WorkflowId)
+	res, err := binsClient.GetWorkflowSchedule(WorkflowId)
 
 	assert.Empty(t, res)
-	assert.Nil(t, err)
+	var schemaErr client.ErrUpstreamSchema
+	assert.ErrorAs(t, err, &schemaErr)
 }
 
 func TestEmptyWorkflowScheduleFound(t *testing.T) {
@@ -133,7 +133,7 @@ func TestEmptyWorkflowScheduleFound(t *testing.T) {
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{http.Client{}, clock, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
 
 	res, err := client.GetWorkflowSchedule(WorkflowId)
 
@@ -160,14 +160,14 @@ func TestSuccessWorkflowSchedule(t *testing.T) {
 	london, _ := time.LoadLocation("Europe/London")
 	now := time.Date(2023, 12, 15, 3, 19, 46, 72, london)
 	clock := clockwork.NewFakeClockAt(now)
-	client := client.BinsClient{http.Client{}, clock, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
 
-	res, err := client.GetWorkflowSchedule(BinId)
+	res, err := binsClient.GetWorkflowSchedule(BinId)
 
-	a := time.Date(2023, 12, 22, 0, 0, 0, 0, london)
-	b := time.Date(2024, 1, 5, 0, 0, 0, 0, london)
-	c := time.Date(2025, 7, 6, 0, 0, 0, 0, london)
-	assert.Equal(t, []time.Time{a, b, c}, res)
+	a := client.NewDate(2023, 12, 22)
+	b := client.NewDate(2024, 1, 5)
+	c := client.NewDate(2025, 7, 6)
+	assert.Equal(t, []client.Date{a, b, c}, res)
 	assert.Nil(t, err)
 }
 
@@ -190,13 +190,13 @@ func TestFilterWorkflowsInPast(t *testing.T) {
 	london, _ := time.LoadLocation("Europe/London")
 	now := time.Date(2024, 1, 1, 3, 19, 46, 72, london)
 	clock := clockwork.NewFakeClockAt(now)
-	client := client.BinsClient{http.Client{}, clock, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
 
-	res, err := client.GetWorkflowSchedule(BinId)
+	res, err := binsClient.GetWorkflowSchedule(BinId)
 
-	b := time.Date(2024, 1, 5, 0, 0, 0, 0, london)
-	c := time.Date(2025, 7, 6, 0, 0, 0, 0, london)
-	assert.Equal(t, []time.Time{b, c}, res)
+	b := client.NewDate(2024, 1, 5)
+	c := client.NewDate(2025, 7, 6)
+	assert.Equal(t, []client.Date{b, c}, res)
 	assert.Nil(t, err)
 }
 
@@ -219,7 +219,7 @@ func TestFetchScheduleTwiceWithoutCache(t *testing.T) {
 	london, _ := time.LoadLocation("Europe/London")
 	now := time.Date(2023, 12, 15, 3, 19, 46, 72, london)
 	clock := clockwork.NewFakeClockAt(now)
-	client := client.BinsClient{http.Client{}, clock, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
 
 	client.GetWorkflowSchedule(BinId)
 	client.GetWorkflowSchedule(BinId)
@@ -246,11 +246,99 @@ func TestFetchScheduleOnceWithCache(t *testing.T) {
 	london, _ := time.LoadLocation("Europe/London")
 	now := time.Date(2023, 12, 15, 3, 19, 46, 72, london)
 	clock := clockwork.NewFakeClockAt(now)
-	cache := expirable.NewLRU[string, interface{}](1024, nil, time.Minute*10)
-	client := client.BinsClient{http.Client{}, clock, apiUrl, cache}
+	cache, _ := client.NewMemoryCache(1024, nil)
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, Cache: cache}
 
 	client.GetWorkflowSchedule(BinId)
 	client.GetWorkflowSchedule(BinId)
 
 	assert.Equal(t, fetches, 1)
 }
+
+func TestFilterCachedScheduleWhenRead(t *testing.T) {
+	fetches := 0
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `
+			{
+				"trigger": {
+					"dates": [
+						"2023-12-22T13:55:42.123Z",
+						"2024-01-05T09:22:31.000Z"
+					]
+				}
+			}
+		`)
+		fetches += 1
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	london, _ := time.LoadLocation("Europe/London")
+	now := time.Date(2023, 12, 15, 3, 19, 46, 72, london)
+	clock := clockwork.NewFakeClockAt(now)
+	cache, _ := client.NewMemoryCache(1024, clock)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, Cache: cache}
+
+	before, _ := binsClient.GetWorkflowSchedule(WorkflowId)
+	clock.Advance(time.Hour * 24 * 8)
+	after, err := binsClient.GetWorkflowSchedule(WorkflowId)
+
+	a := client.NewDate(2023, 12, 22)
+	b := client.NewDate(2024, 1, 5)
+	assert.Equal(t, []client.Date{a, b}, before)
+	assert.Equal(t, []client.Date{b}, after)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, fetches)
+}
+
+func TestKeepCollectionDueToday(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `
+			{
+				"trigger": {
+					"dates": [
+						"2023-12-15T07:00:00.000Z",
+						"2023-12-22T07:00:00.000Z"
+					]
+				}
+			}
+		`)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	london, _ := time.LoadLocation("Europe/London")
+	now := time.Date(2023, 12, 15, 23, 59, 0, 0, london)
+	clock := clockwork.NewFakeClockAt(now)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+
+	res, err := binsClient.GetWorkflowSchedule(WorkflowId)
+
+	a := client.NewDate(2023, 12, 15)
+	b := client.NewDate(2023, 12, 22)
+	assert.Equal(t, []client.Date{a, b}, res)
+	assert.Nil(t, err)
+}
+
+func TestWorkflowScheduleUsesLondonDates(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `
+			{
+				"trigger": {
+					"dates": [
+						"2024-01-05T23:30:00.000Z",
+						"2024-07-05T23:30:00.000Z"
+					]
+				}
+			}
+		`)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+
+	res, err := binsClient.GetWorkflowSchedule(WorkflowId)
+
+	// Midnight during BST is 11pm the day before in UTC
+	assert.Equal(t, []client.Date{client.NewDate(2024, 1, 5), client.NewDate(2024, 7, 6)}, res)
+	assert.Nil(t, err)
+}
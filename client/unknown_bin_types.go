@@ -0,0 +1,78 @@
+package client
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/jonboulle/clockwork"
+)
+
+// A type of container that no classification rule matched.
+type UnknownBinType struct {
+	TypeId    string
+	SubTitle  string
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// How many times it has been fetched from the Council's API.
+	Count int
+	// A bin of this type, to look up when writing a rule for it.
+	ExampleBinId string
+}
+
+// Keeps track of the types of container that no classification rule matched,
+// so that rules can be written for them. Holds up to a fixed number of types,
+// forgetting the least recently seen first. Safe for concurrent use.
+type UnknownBinTypes struct {
+	clock   clockwork.Clock
+	mu      sync.Mutex
+	entries *lru.Cache[string, UnknownBinType]
+}
+
+// Nil clock means the real clock.
+func NewUnknownBinTypes(size int, clock clockwork.Clock) (*UnknownBinTypes, error) {
+	entries, err := lru.New[string, UnknownBinType](size)
+	if err != nil {
+		return nil, err
+	}
+	if clock == nil {
+		clock = clockwork.NewRealClock()
+	}
+	return &UnknownBinTypes{clock: clock, entries: entries}, nil
+}
+
+func (u *UnknownBinTypes) record(binId string, b BinType) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := u.clock.Now()
+	key := b.TypeId + " " + b.Name
+	entry, found := u.entries.Get(key)
+	if !found {
+		entry = UnknownBinType{
+			TypeId:       b.TypeId,
+			SubTitle:     b.Name,
+			FirstSeen:    now,
+			ExampleBinId: binId,
+		}
+	}
+	entry.LastSeen = now
+	entry.Count++
+	u.entries.Add(key, entry)
+}
+
+// Every unknown type being kept, most often seen first.
+func (u *UnknownBinTypes) List() []UnknownBinType {
+	u.mu.Lock()
+	res := u.entries.Values()
+	u.mu.Unlock()
+
+	slices.SortStableFunc(res, func(a, b UnknownBinType) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	return res
+}
@@ -0,0 +1,96 @@
+package client_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+// Answers with a bin named after the last part of the URL, of a type no rule knows.
+func unknownBinTypeServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		name := strings.TrimSuffix(parts[len(parts)-1], "-again")
+		if name == "caddy" {
+			fmt.Fprintf(w, `{"subTitle": "Caddy", "binType": "5f96b455e36673006420c529"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"subTitle": %q, "binType": "id-%v"}`, name, name)
+	}))
+}
+
+func TestRecordUnknownBinTypes(t *testing.T) {
+	apiSvr := unknownBinTypeServer()
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := clockwork.NewFakeClockAt(start)
+	unknown, _ := client.NewUnknownBinTypes(10, clock)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, UnknownBinTypes: unknown}
+
+	binsClient.GetBinType("skip")
+	clock.Advance(time.Hour)
+	binsClient.GetBinType("skip-again")
+	binsClient.GetBinType("caddy")
+
+	assert.Equal(t, []client.UnknownBinType{
+		{
+			TypeId:       "id-skip",
+			SubTitle:     "skip",
+			FirstSeen:    start,
+			LastSeen:     start.Add(time.Hour),
+			Count:        2,
+			ExampleBinId: "skip",
+		},
+	}, unknown.List())
+}
+
+func TestListMostSeenUnknownBinTypesFirst(t *testing.T) {
+	apiSvr := unknownBinTypeServer()
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	unknown, _ := client.NewUnknownBinTypes(10, clock)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, UnknownBinTypes: unknown}
+
+	binsClient.GetBinType("skip")
+	clock.Advance(time.Minute)
+	binsClient.GetBinType("hopper")
+	clock.Advance(time.Minute)
+	binsClient.GetBinType("trough")
+	binsClient.GetBinType("trough-again")
+
+	var names []string
+	for _, u := range unknown.List() {
+		names = append(names, u.SubTitle)
+	}
+	assert.Equal(t, []string{"trough", "hopper", "skip"}, names)
+}
+
+func TestForgetLeastRecentlySeenUnknownBinTypes(t *testing.T) {
+	apiSvr := unknownBinTypeServer()
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	unknown, _ := client.NewUnknownBinTypes(2, clock)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, UnknownBinTypes: unknown}
+
+	binsClient.GetBinType("skip")
+	binsClient.GetBinType("hopper")
+	binsClient.GetBinType("skip-again")
+	binsClient.GetBinType("trough")
+
+	var names []string
+	for _, u := range unknown.List() {
+		names = append(names, u.SubTitle)
+	}
+	assert.ElementsMatch(t, []string{"skip", "trough"}, names)
+}
@@ -1,39 +1,45 @@
 package client
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
+	"context"
 	"io/ioutil"
 	"net/http"
+	"slices"
 )
 
-func (c BinsClient) GetBinWorkflowId(binId string) (string, error) {
-	target := c.ApiHost.JoinPath(workflowIdUrl, binId).String()
+// A bin can follow several workflows at once, e.g. its regular round plus a
+// seasonal or bank holiday replacement, so return all of them.
+func (c BinsClient) GetBinWorkflowIds(binId string) ([]string, error) {
+	return c.GetBinWorkflowIdsContext(context.Background(), binId)
+}
 
-	if c.Cache != nil {
-		if res, found := c.Cache.Get(target); found {
-			return res.(string), nil
-		}
-	}
+func (c BinsClient) GetBinWorkflowIdsContext(ctx context.Context, binId string) ([]string, error) {
+	target := c.ApiHost.JoinPath(workflowIdUrl, binId).String()
+	return cached(ctx, c, CachedWorkflowIds, target, func(ctx context.Context) ([]string, error) {
+		return c.fetchBinWorkflowIds(ctx, target)
+	})
+}
 
-	req, err := http.NewRequest(http.MethodGet, target, nil)
+func (c BinsClient) fetchBinWorkflowIds(ctx context.Context, target string) ([]string, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
 	if err != nil {
-		return "", err
+		return []string{}, err
 	}
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("User-Agent", userAgent)
 
-	resp, err := c.HttpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return "", err
+		return []string{}, err
 	}
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("Status code %v fetching workflows of bins", resp.StatusCode)
+		return []string{}, c.statusError(resp, "workflows of bins")
 	}
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return []string{}, err
 	}
 
 	type result struct {
@@ -41,17 +47,27 @@ func (c BinsClient) GetBinWorkflowId(binId string) (string, error) {
 	}
 
 	var data result
-	json.Unmarshal(respBody, &data)
+	if err := decodeResponse(workflowIdUrl, respBody, &data); err != nil {
+		return []string{}, err
+	}
+	if data.IDs == nil {
+		return []string{}, missingField(workflowIdUrl, "scheduleCodeWorkflowIDs")
+	}
 
 	if len(data.IDs) == 0 {
-		return "", errors.New("Workflow IDs not found")
-	}
-	if data.IDs[0] == "" {
-		return "", errors.New("Workflow ID not found")
+		return []string{}, notFound("Workflow IDs not found")
 	}
 
-	if c.Cache != nil {
-		c.Cache.Add(target, data.IDs[0])
+	var ids []string
+	for _, id := range data.IDs {
+		if id == "" || slices.Contains(ids, id) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return []string{}, notFound("Workflow ID not found")
 	}
-	return data.IDs[0], nil
+
+	return ids, nil
 }
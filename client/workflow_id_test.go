@@ -9,18 +9,16 @@ import (
 	"net/url"
 	"testing"
 	"testing/iotest"
-	"time"
 
 	"github.com/dinosaursrarr/hackney-bindicator/client"
-	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestBadWorkflowIdUrl(t *testing.T) {
 	badUrl, _ := url.Parse("ftp://foo.bar")
-	client := client.BinsClient{http.Client{}, nil, badUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: badUrl}
 
-	res, err := client.GetBinWorkflowId(BinId)
+	res, err := client.GetBinWorkflowIds(BinId)
 
 	assert.Empty(t, res)
 	assert.Contains(t, err.Error(), "unsupported protocol scheme")
@@ -32,9 +30,9 @@ func TestSetUserAgentGettingWorkflowId(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
-	client.GetBinWorkflowId(BinId)
+	client.GetBinWorkflowIds(BinId)
 }
 
 func TestSetAcceptGettingWorkflowId(t *testing.T) {
@@ -43,9 +41,9 @@ func TestSetAcceptGettingWorkflowId(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
-	client.GetBinWorkflowId(BinId)
+	client.GetBinWorkflowIds(BinId)
 }
 
 func TestHttpErrorGettingWorkflowId(t *testing.T) {
@@ -59,9 +57,9 @@ func TestHttpErrorGettingWorkflowId(t *testing.T) {
 			},
 		},
 	}
-	client := client.BinsClient{httpClient, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: httpClient, ApiHost: apiUrl}
 
-	res, err := client.GetBinWorkflowId(BinId)
+	res, err := client.GetBinWorkflowIds(BinId)
 
 	assert.Empty(t, res)
 	assert.Contains(t, err.Error(), "foo")
@@ -73,9 +71,9 @@ func TestBadStatusCodeGettingWorkflowId(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
-	res, err := client.GetBinWorkflowId(BinId)
+	res, err := client.GetBinWorkflowIds(BinId)
 
 	assert.Empty(t, res)
 	assert.Contains(t, err.Error(), "Status code 418")
@@ -95,24 +93,25 @@ func TestErrorReadingWorkflowId(t *testing.T) {
 			},
 		},
 	}
-	client := client.BinsClient{httpClient, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: httpClient, ApiHost: apiUrl}
 
-	res, err := client.GetBinWorkflowId(BinId)
+	res, err := client.GetBinWorkflowIds(BinId)
 
 	assert.Empty(t, res)
 	assert.Contains(t, err.Error(), "nope")
 }
 
-func TestWorkflowIdNotFound(t *testing.T) {
+func TestWorkflowIdEmptyResponse(t *testing.T) {
 	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	binsClient := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
-	res, err := client.GetBinWorkflowId(BinId)
+	res, err := binsClient.GetBinWorkflowIds(BinId)
 
 	assert.Empty(t, res)
-	assert.Contains(t, err.Error(), "Workflow IDs not found")
+	var schemaErr client.ErrUpstreamSchema
+	assert.ErrorAs(t, err, &schemaErr)
 }
 
 func TestEmptyWorkflowIdList(t *testing.T) {
@@ -125,9 +124,9 @@ func TestEmptyWorkflowIdList(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
-	res, err := client.GetBinWorkflowId(BinId)
+	res, err := client.GetBinWorkflowIds(BinId)
 
 	assert.Empty(t, res)
 	assert.Contains(t, err.Error(), "Workflow IDs not found")
@@ -143,9 +142,9 @@ func TestEmptyFirstWorkflowId(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
-	res, err := client.GetBinWorkflowId(BinId)
+	res, err := client.GetBinWorkflowIds(BinId)
 
 	assert.Empty(t, res)
 	assert.Contains(t, err.Error(), "Workflow ID not found")
@@ -161,11 +160,29 @@ func TestSuccessWorkflowId(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
+
+	res, err := client.GetBinWorkflowIds(BinId)
+
+	assert.Equal(t, res, []string{"foo"})
+	assert.Nil(t, err)
+}
+
+func TestSuccessMultipleWorkflowIds(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `
+			{
+				"scheduleCodeWorkflowIDs": ["foo", "", "bar", "foo"]
+			}
+		`)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
-	res, err := client.GetBinWorkflowId(BinId)
+	res, err := client.GetBinWorkflowIds(BinId)
 
-	assert.Equal(t, res, "foo")
+	assert.Equal(t, res, []string{"foo", "bar"})
 	assert.Nil(t, err)
 }
 
@@ -181,10 +198,10 @@ func TestFetchWorkflowIdTwiceWithoutCache(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, nil}
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl}
 
-	client.GetBinWorkflowId(BinId)
-	client.GetBinWorkflowId(BinId)
+	client.GetBinWorkflowIds(BinId)
+	client.GetBinWorkflowIds(BinId)
 
 	assert.Equal(t, fetches, 2)
 }
@@ -201,11 +218,11 @@ func TestFetchWorkflowIdOnceWithCache(t *testing.T) {
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
-	cache := expirable.NewLRU[string, interface{}](1024, nil, time.Minute*10)
-	client := client.BinsClient{http.Client{}, nil, apiUrl, cache}
+	cache, _ := client.NewMemoryCache(1024, nil)
+	client := client.BinsClient{HttpClient: http.Client{}, ApiHost: apiUrl, Cache: cache}
 
-	client.GetBinWorkflowId(BinId)
-	client.GetBinWorkflowId(BinId)
+	client.GetBinWorkflowIds(BinId)
+	client.GetBinWorkflowIds(BinId)
 
 	assert.Equal(t, fetches, 1)
 }
@@ -7,52 +7,43 @@ import (
 
 	"github.com/dinosaursrarr/hackney-bindicator/client"
 	"github.com/gorilla/mux"
-	"github.com/hashicorp/golang-lru/v2/expirable"
 )
 
 type AddressHandler struct {
-	Client client.BinsClient
-	Cache  *expirable.LRU[string, interface{}]
+	Client    client.BinsClient
+	Cache     client.Cache
+	CacheTTLs client.TTLs
 }
 
 func (h *AddressHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postcode := vars["postcode"]
 	if postcode == "" {
-		http.Error(w, "URL did not include postcode", http.StatusBadRequest)
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "URL did not include postcode")
 		return
 	}
 
-	if h.Cache != nil {
-		if res, found := h.Cache.Get(r.URL.String()); found {
-			result := res.(string)
-			io.WriteString(w, result)
-			return
-		}
-	}
-
-	addresses, err := h.Client.GetAddresses(postcode)
-	if err == client.NotHackneyErr {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	if err == client.InvalidPostcodeErr {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if res, found := client.CachedResponses.Get(h.Cache, r.URL.String()); found {
+		io.WriteString(w, res)
 		return
 	}
+
+	ctx, staleness := client.TrackStaleness(r.Context())
+	addresses, err := h.Client.GetAddressesContext(ctx, postcode)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
+	stale := markStale(w, staleness)
 	resBytes, err := json.Marshal(addresses)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, http.StatusInternalServerError, err.Error())
 		return
 	}
 	res := string(resBytes)
-	if h.Cache != nil {
-		h.Cache.Add(r.URL.String(), res)
+	if !stale {
+		client.CachedResponses.Add(h.Cache, h.CacheTTLs, r.URL.String(), res)
 	}
 	w.Header().Set("Content-Type", "application/json")
 	io.WriteString(w, res)
@@ -11,7 +11,6 @@ import (
 	"github.com/dinosaursrarr/hackney-bindicator/client"
 	"github.com/dinosaursrarr/hackney-bindicator/handler"
 	"github.com/gorilla/mux"
-	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/jonboulle/clockwork"
 	"github.com/stretchr/testify/assert"
 )
@@ -38,8 +37,8 @@ func TestNoPostcode(t *testing.T) {
 	r = mux.SetURLVars(r, vars)
 	httpClient := http.Client{}
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{httpClient, clock, &url.URL{}, nil}
-	handler := handler.AddressHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: &url.URL{}}
+	handler := handler.AddressHandler{Client: client}
 
 	handler.Handle(w, r)
 
@@ -56,8 +55,8 @@ func TestNonHackneyPostcode(t *testing.T) {
 	r = mux.SetURLVars(r, vars)
 	httpClient := http.Client{}
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{httpClient, clock, &url.URL{}, nil}
-	handler := handler.AddressHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: &url.URL{}}
+	handler := handler.AddressHandler{Client: client}
 
 	handler.Handle(w, r)
 
@@ -74,8 +73,8 @@ func TestInvalidPostcode(t *testing.T) {
 	r = mux.SetURLVars(r, vars)
 	httpClient := http.Client{}
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{httpClient, clock, &url.URL{}, nil}
-	handler := handler.AddressHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: &url.URL{}}
+	handler := handler.AddressHandler{Client: client}
 
 	handler.Handle(w, r)
 
@@ -97,13 +96,13 @@ func TestOtherErrorGettingAddresses(t *testing.T) {
 	r = mux.SetURLVars(r, vars)
 	httpClient := http.Client{}
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	handler := handler.AddressHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.AddressHandler{Client: client}
 
 	handler.Handle(w, r)
 
 	assert.Equal(t, w.Code, http.StatusInternalServerError)
-	assert.Contains(t, w.Body.String(), "fetching addresses")
+	assert.Contains(t, w.Body.String(), "Something went wrong")
 }
 
 func TestSuccess(t *testing.T) {
@@ -120,8 +119,8 @@ func TestSuccess(t *testing.T) {
 	r = mux.SetURLVars(r, vars)
 	httpClient := http.Client{}
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	handler := handler.AddressHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.AddressHandler{Client: client}
 
 	handler.Handle(w, r)
 
@@ -155,8 +154,8 @@ func TestFetchAddressesTwiceWithoutCache(t *testing.T) {
 	r2 = mux.SetURLVars(r2, vars)
 	httpClient := http.Client{}
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	handler := handler.AddressHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.AddressHandler{Client: client}
 
 	handler.Handle(w1, r1)
 	handler.Handle(w2, r2)
@@ -183,12 +182,82 @@ func TestFetchAddressesOnceWithCache(t *testing.T) {
 	r2 = mux.SetURLVars(r2, vars)
 	httpClient := http.Client{}
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	cache := expirable.NewLRU[string, interface{}](1024, nil, time.Minute*10)
-	handler := handler.AddressHandler{client, cache}
+	cache, _ := client.NewMemoryCache(1024, clock)
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.AddressHandler{Client: client, Cache: cache}
 
 	handler.Handle(w1, r1)
 	handler.Handle(w2, r2)
 
 	assert.Equal(t, fetches, 1)
 }
+
+func TestAddressesServiceUnavailableWhenCircuitOpen(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	vars := map[string]string{
+		"postcode": Postcode,
+	}
+	clock := clockwork.NewFakeClock()
+	breaker := &client.CircuitBreaker{Threshold: 1, Cooldown: time.Minute, Clock: clock}
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, Breaker: breaker}
+	handler := handler.AddressHandler{Client: client}
+
+	r1, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	handler.Handle(httptest.NewRecorder(), mux.SetURLVars(r1, vars))
+	r2, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	handler.Handle(w, mux.SetURLVars(r2, vars))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+}
+
+func TestAddressesBadGatewayWhenApiResponseChanges(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `not json`)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"postcode": "E8 1EA",
+	}
+	r = mux.SetURLVars(r, vars)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.AddressHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), "Unexpected response")
+}
+
+func TestInvalidPostcodeProblemJson(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/addresses/E81EAXXX", nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"postcode": "E8 1EAXXX",
+	}
+	r = mux.SetURLVars(r, vars)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: &url.URL{}}
+	handler := handler.AddressHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `
+		{
+			"type": "/problems/invalid-input",
+			"title": "Bad Request",
+			"status": 400,
+			"detail": "Not a valid postcode",
+			"instance": "/addresses/E81EAXXX"
+		}`, w.Body.String())
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/gorilla/mux"
+)
+
+const calendarProductId = "-//dinosaursrarr//Hackney Bindicator//EN"
+const calendarUidDomain = "hackney-bindicator"
+
+// Collections are all-day events starting at midnight, so this fires at 6pm
+// the evening before, which is when bins need to go out.
+const calendarReminder = "-PT6H"
+
+// RFC 5545 says lines should not be longer than 75 octets.
+const calendarLineLimit = 75
+
+type CalendarHandler struct {
+	Client    client.BinsClient
+	Cache     client.Cache
+	CacheTTLs client.TTLs
+}
+
+func (h *CalendarHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	propertyId := vars["property_id"]
+	if propertyId == "" {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "URL did not include property_id")
+		return
+	}
+
+	if res, found := client.CachedResponses.Get(h.Cache, r.URL.String()); found {
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		io.WriteString(w, res)
+		return
+	}
+
+	ctx, staleness := client.TrackStaleness(r.Context())
+	p, err := fetchProperty(ctx, h.Client, propertyId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	stale := markStale(w, staleness)
+	res := renderCalendar(p, h.Client.Clock.Now())
+	cacheProperty(h.Cache, h.CacheTTLs, r.URL.String(), res, h.Client.Clock.Now(), stale)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	io.WriteString(w, res)
+}
+
+func renderCalendar(p property, now time.Time) string {
+	var sb strings.Builder
+	line := func(s string) {
+		sb.WriteString(foldCalendarLine(s))
+		sb.WriteString("\r\n")
+	}
+	stamp := now.UTC().Format("20060102T150405Z")
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:" + calendarProductId)
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeCalendarText("Bin collections for "+p.Name))
+	for _, b := range p.Bins {
+		refuse := b.Type.Type.String()
+		if b.Type.Communal {
+			refuse = "communal " + refuse
+		}
+		if b.Type.Trade {
+			refuse = "trade " + refuse
+		}
+		summary := fmt.Sprintf("%v collection (%v)", capitalize(refuse), b.Type.Name)
+		for _, date := range b.Schedule {
+			day := calendarDate(date)
+			line("BEGIN:VEVENT")
+			line(fmt.Sprintf("UID:%v-%v@%v", b.Id, day, calendarUidDomain))
+			line("DTSTAMP:" + stamp)
+			line("DTSTART;VALUE=DATE:" + day)
+			line("DTEND;VALUE=DATE:" + calendarDate(date.AddDays(1)))
+			line("SUMMARY:" + escapeCalendarText(summary))
+			line("TRANSP:TRANSPARENT")
+			line("BEGIN:VALARM")
+			line("ACTION:DISPLAY")
+			line("DESCRIPTION:" + escapeCalendarText("Put out "+b.Type.Name+" tonight"))
+			line("TRIGGER:" + calendarReminder)
+			line("END:VALARM")
+			line("END:VEVENT")
+		}
+	}
+	line("END:VCALENDAR")
+	return sb.String()
+}
+
+func calendarDate(d client.Date) string {
+	return d.In(time.UTC).Format("20060102")
+}
+
+func escapeCalendarText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// Long lines are split with a CRLF followed by a single space. Avoid
+// splitting in the middle of a multi-byte character.
+func foldCalendarLine(s string) string {
+	var sb strings.Builder
+	limit := calendarLineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(s[cut]) {
+			cut--
+		}
+		sb.WriteString(s[:cut])
+		sb.WriteString("\r\n ")
+		s = s[cut:]
+		limit = calendarLineLimit - 1 // Allow for the leading space
+	}
+	sb.WriteString(s)
+	return sb.String()
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,206 @@
+package handler_test
+
+import (
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/handler"
+
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	_ "time/tzdata"
+
+	"github.com/gorilla/mux"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendarNoPropertyId(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": "",
+	}
+	r = mux.SetURLVars(r, vars)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: &url.URL{}}
+	handler := handler.CalendarHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "include property_id")
+}
+
+func TestCalendarBadPropertyId(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadRequest)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CalendarHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "fetching list of bins")
+}
+
+func TestCalendarEventForEveryUpcomingCollection(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	london, _ := time.LoadLocation("Europe/London")
+	now := time.Date(2023, 12, 15, 3, 19, 46, 72, london)
+	clock := clockwork.NewFakeClockAt(now)
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CalendarHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(body, "END:VCALENDAR\r\n"))
+	assert.Equal(t, 4, strings.Count(body, "BEGIN:VEVENT"))
+	assert.Equal(t, 4, strings.Count(body, "BEGIN:VALARM"))
+	assert.Contains(t, body, "UID:bin1-20240101@hackney-bindicator\r\n")
+	assert.Contains(t, body, "UID:bin1-20250701@hackney-bindicator\r\n")
+	assert.Contains(t, body, "UID:bin2-20240102@hackney-bindicator\r\n")
+	assert.Contains(t, body, "UID:bin2-20250702@hackney-bindicator\r\n")
+	assert.NotContains(t, body, "20231201")
+	assert.Contains(t, body, "DTSTART;VALUE=DATE:20250701\r\nDTEND;VALUE=DATE:20250702\r\n")
+	assert.Contains(t, body, "DTSTAMP:20231215T031946Z\r\n")
+	assert.Contains(t, body, "SUMMARY:Garden collection (Garbage can)\r\n")
+	assert.Contains(t, body, "SUMMARY:Unknown collection (Dumpster)\r\n")
+	assert.Contains(t, body, "TRIGGER:-PT6H\r\n")
+}
+
+func TestCalendarEscapesAndFoldsText(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.String(), PropertyId) {
+			fmt.Fprintf(w, `{
+				"addressSummary": "Flat 1, Block A; Some Very Long Estate Name That Goes On And On, London",
+				"providerSpecificFields": {
+					"attributes_wasteContainersAssignableWasteContainers": "`+BinId1+`"
+				}
+			}`)
+		}
+		if strings.Contains(r.URL.String(), "/getbin/") {
+			fmt.Fprintf(w, Bin1TypeJsonResponse)
+		}
+		if strings.Contains(r.URL.String(), "/getcollection/") {
+			fmt.Fprintf(w, Bin1WorkflowIdJsonResponse)
+		}
+		if strings.Contains(r.URL.String(), "/getworkflow/") {
+			fmt.Fprintf(w, Workflow1ScheduleJsonResponse)
+		}
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CalendarHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `X-WR-CALNAME:Bin collections for Flat 1\, Block A\; Some Very Long Estate`)
+	for _, l := range strings.Split(body, "\r\n") {
+		assert.LessOrEqual(t, len(l), 75)
+	}
+	unfolded := strings.ReplaceAll(body, "\r\n ", "")
+	assert.Contains(t, unfolded, `X-WR-CALNAME:Bin collections for Flat 1\, Block A\; Some Very Long Estate Name That Goes On And On\, London`+"\r\n")
+}
+
+func TestCalendarSaysWhichBinsAreCommunalOrTrade(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/getproperty/"):
+			fmt.Fprintf(w, `{
+				"addressSummary": "Estate",
+				"providerSpecificFields": {
+					"attributes_wasteContainersAssignableWasteContainers": "`+BinId1+`,`+BinId2+`"
+				}
+			}`)
+		case strings.Contains(r.URL.Path, "/getbin/"+BinId1):
+			fmt.Fprintf(w, `{"subTitle": "ES_Refuse 1100L", "binType": "abc"}`)
+		case strings.Contains(r.URL.Path, "/getbin/"+BinId2):
+			fmt.Fprintf(w, `{"subTitle": "Eurobin", "binType": "619f87d15c9f9c016ce81494"}`)
+		case strings.Contains(r.URL.Path, "/getcollection/"):
+			fmt.Fprintf(w, Bin1WorkflowIdJsonResponse)
+		case strings.Contains(r.URL.Path, "/getworkflow/"):
+			fmt.Fprintf(w, Workflow1ScheduleJsonResponse)
+		}
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	r = mux.SetURLVars(r, map[string]string{"property_id": PropertyId})
+	clock := clockwork.NewFakeClockAt(time.Date(2023, 12, 15, 12, 0, 0, 0, time.UTC))
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CalendarHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "SUMMARY:Communal rubbish collection (ES_Refuse 1100L)\r\n")
+	assert.Contains(t, w.Body.String(), "SUMMARY:Trade rubbish collection (Eurobin)\r\n")
+}
+
+func TestCalendarFetchOnceWithCache(t *testing.T) {
+	fetches := make(map[string]int)
+	apiSvr := propertyApiServer(fetches)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r1, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w1 := httptest.NewRecorder()
+	r2, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w2 := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r1 = mux.SetURLVars(r1, vars)
+	r2 = mux.SetURLVars(r2, vars)
+	clock := clockwork.NewFakeClock()
+	cache, _ := client.NewMemoryCache(1024, clock)
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CalendarHandler{Client: client, Cache: cache}
+
+	handler.Handle(w1, r1)
+	handler.Handle(w2, r2)
+
+	assert.Equal(t, http.StatusOK, w2.Code)
+	assert.Equal(t, w1.Body.String(), w2.Body.String())
+	assert.Equal(t, "text/calendar; charset=utf-8", w2.Header().Get("Content-Type"))
+	for _, v := range fetches {
+		assert.Equal(t, 1, v)
+	}
+}
@@ -4,126 +4,123 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
-	"sync"
-	"time"
 
 	"github.com/dinosaursrarr/hackney-bindicator/client"
 	"github.com/gorilla/mux"
-	"github.com/hashicorp/golang-lru/v2/expirable"
-	"golang.org/x/sync/errgroup"
 )
 
 type CollectionHandler struct {
-	Client client.BinsClient
-	Cache  *expirable.LRU[string, interface{}]
+	Client    client.BinsClient
+	Cache     client.Cache
+	CacheTTLs client.TTLs
+	// Give dates as timestamps at midnight in London, as they used to be.
+	LegacyDates bool
 }
 
 func (h *CollectionHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	propertyId := vars["property_id"]
 	if propertyId == "" {
-		http.Error(w, "URL did not include property_id", http.StatusBadRequest)
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "URL did not include property_id")
 		return
 	}
 
-	if h.Cache != nil {
-		if res, found := h.Cache.Get(r.URL.String()); found {
-			result := res.(string)
-			io.WriteString(w, result)
-			return
-		}
+	// Bins are always listed. The collections view also groups them by the
+	// day they are next collected.
+	view := r.URL.Query().Get("view")
+	if view != "" && view != "bins" && view != "collections" {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "view must be bins or collections")
+		return
 	}
 
-	binIds, err := h.Client.GetBinIds(propertyId)
-	if err != nil {
-		if err == client.ErrBadPropertyId {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if res, found := client.CachedResponses.Get(h.Cache, r.URL.String()); found {
+		io.WriteString(w, res)
 		return
 	}
 
-	g := new(errgroup.Group)
-	binTypes := make([]client.BinType, len(binIds.Ids))
-	binWorkflowIds := make([]string, len(binIds.Ids))
-	var schedulesStarted sync.Map
-	var schedules sync.Map
-	for i, binId := range binIds.Ids {
-		i := i
-		binId := binId
-		g.Go(func() error {
-			binType, err := h.Client.GetBinType(binId)
-			if err != nil {
-				return err
-			}
-			binTypes[i] = binType
-			return nil
-		})
-		g.Go(func() error {
-			workflowId, err := h.Client.GetBinWorkflowId(binId)
-			if err != nil {
-				return err
-			}
-			binWorkflowIds[i] = workflowId
-			// Fetch the schedule as soon as we see this ID, but only the first time.
-			if _, ok := schedulesStarted.Load(workflowId); ok {
-				return nil
-			}
-			schedulesStarted.Store(workflowId, true)
-			schedule, err := h.Client.GetWorkflowSchedule(workflowId)
-			if err != nil {
-				return err
-			}
-			schedules.Store(workflowId, schedule)
-			return nil
-		})
-	}
-	if err := g.Wait(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	ctx, staleness := client.TrackStaleness(r.Context())
+	p, err := fetchProperty(ctx, h.Client, propertyId)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	type bin struct {
-		Name           string
-		Type           string
-		NextCollection time.Time
+		Id   string
+		Name string
+		Type string
+		// Which sort of container it is, for showing the right icon.
+		TypeId         string `json:",omitempty"`
+		Kind           string
+		Capacity       int  `json:",omitempty"`
+		Communal       bool `json:",omitempty"`
+		Trade          bool `json:",omitempty"`
+		NextCollection responseDate
+	}
+	type collection struct {
+		Date    responseDate
+		Summary string
+		Types   []string
+		Bins    []bin
 	}
 	type result struct {
-		PropertyId string
-		Name       string
-		Bins       []bin
+		PropertyId  string
+		Name        string
+		Bins        []bin
+		Collections []collection `json:",omitempty"`
+		Stale       bool         `json:",omitempty"`
+	}
+	toBin := func(b propertyBin) bin {
+		return bin{
+			Id:             b.Id,
+			Name:           b.Type.Name,
+			Type:           b.Type.Type.String(),
+			TypeId:         b.Type.TypeId,
+			Kind:           b.Type.Kind.String(),
+			Capacity:       b.Type.Capacity,
+			Communal:       b.Type.Communal,
+			Trade:          b.Type.Trade,
+			NextCollection: responseDate{b.Schedule[0], h.LegacyDates},
+		}
 	}
 	var bins []bin
-	for i, _ := range binIds.Ids {
-		s, ok := schedules.Load(binWorkflowIds[i])
-		if !ok {
+	for _, b := range p.Bins {
+		if len(b.Schedule) == 0 {
 			continue
 		}
-		schedule := s.([]time.Time)
-		if len(schedule) == 0 {
-			continue
+		bins = append(bins, toBin(b))
+	}
+	var collections []collection
+	if view == "collections" {
+		for _, day := range collectionDays(p.Bins, 1) {
+			c := collection{
+				Date:    responseDate{day.Date, h.LegacyDates},
+				Summary: summarise(day.Bins),
+			}
+			for _, t := range refuseTypes(day.Bins) {
+				c.Types = append(c.Types, t.String())
+			}
+			for _, b := range day.Bins {
+				c.Bins = append(c.Bins, toBin(b))
+			}
+			collections = append(collections, c)
 		}
-		bins = append(bins, bin{
-			Name:           binTypes[i].Name,
-			Type:           binTypes[i].Type.String(),
-			NextCollection: schedule[0],
-		})
 	}
 
+	stale := markStale(w, staleness)
 	resBytes, err := json.Marshal(result{
-		PropertyId: propertyId,
-		Name:       binIds.Name,
-		Bins:       bins,
+		PropertyId:  p.Id,
+		Name:        p.Name,
+		Bins:        bins,
+		Collections: collections,
+		Stale:       stale,
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, http.StatusInternalServerError, err.Error())
 		return
 	}
 	res := string(resBytes)
-	if h.Cache != nil {
-		h.Cache.Add(r.URL.String(), res)
-	}
+	cacheProperty(h.Cache, h.CacheTTLs, r.URL.String(), res, h.Client.Clock.Now(), stale)
 	w.Header().Set("Content-Type", "application/json")
 	io.WriteString(w, res)
 }
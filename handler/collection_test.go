@@ -4,18 +4,21 @@ import (
 	"github.com/dinosaursrarr/hackney-bindicator/client"
 	"github.com/dinosaursrarr/hackney-bindicator/handler"
 
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	_ "time/tzdata"
 
 	"github.com/gorilla/mux"
-	"github.com/hashicorp/golang-lru/v2/expirable"
+	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/jonboulle/clockwork"
 	"github.com/stretchr/testify/assert"
 )
@@ -90,8 +93,8 @@ func TestNoPropertyId(t *testing.T) {
 	r = mux.SetURLVars(r, vars)
 	httpClient := http.Client{}
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{httpClient, clock, &url.URL{}, nil}
-	handler := handler.CollectionHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: &url.URL{}}
+	handler := handler.CollectionHandler{Client: client}
 
 	handler.Handle(w, r)
 
@@ -113,8 +116,8 @@ func TestClientErrorGettingBinIds(t *testing.T) {
 	r = mux.SetURLVars(r, vars)
 	httpClient := http.Client{}
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	handler := handler.CollectionHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
 
 	handler.Handle(w, r)
 
@@ -136,13 +139,17 @@ func TestServerErrorGettingBinIds(t *testing.T) {
 	r = mux.SetURLVars(r, vars)
 	httpClient := http.Client{}
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	handler := handler.CollectionHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
 
 	handler.Handle(w, r)
 
 	assert.Equal(t, w.Code, http.StatusInternalServerError)
-	assert.Contains(t, w.Body.String(), "fetching list of bins")
+	assert.Contains(t, w.Body.String(), "Something went wrong")
+	// The details stay in the log.
+	assert.NotContains(t, w.Body.String(), "fetching list of bins")
+	assert.NotContains(t, w.Body.String(), apiUrl.Host)
+	assert.NotContains(t, w.Body.String(), "nope")
 }
 
 func TestErrorGettingBinTypes(t *testing.T) {
@@ -162,6 +169,12 @@ func TestErrorGettingBinTypes(t *testing.T) {
 		if strings.Contains(r.URL.String(), BinId2) && strings.Contains(r.URL.String(), "/getcollection/") {
 			fmt.Fprintf(w, Bin2WorkflowIdJsonResponse)
 		}
+		if strings.Contains(r.URL.String(), WorkflowId1) && strings.Contains(r.URL.String(), "/getworkflow/") {
+			fmt.Fprintf(w, Workflow1ScheduleJsonResponse)
+		}
+		if strings.Contains(r.URL.String(), WorkflowId2) && strings.Contains(r.URL.String(), "/getworkflow/") {
+			fmt.Fprintf(w, Workflow2ScheduleJsonResponse)
+		}
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
@@ -173,13 +186,13 @@ func TestErrorGettingBinTypes(t *testing.T) {
 	r = mux.SetURLVars(r, vars)
 	httpClient := http.Client{}
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	handler := handler.CollectionHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
 
 	handler.Handle(w, r)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.Contains(t, w.Body.String(), "fetching types of bins")
+	assert.Contains(t, w.Body.String(), "Something went wrong")
 }
 
 func TestErrorGettingBinWorkflowIds(t *testing.T) {
@@ -199,6 +212,12 @@ func TestErrorGettingBinWorkflowIds(t *testing.T) {
 		if strings.Contains(r.URL.String(), BinId2) && strings.Contains(r.URL.String(), "/getcollection/") {
 			fmt.Fprintf(w, Bin2WorkflowIdJsonResponse)
 		}
+		if strings.Contains(r.URL.String(), WorkflowId1) && strings.Contains(r.URL.String(), "/getworkflow/") {
+			fmt.Fprintf(w, Workflow1ScheduleJsonResponse)
+		}
+		if strings.Contains(r.URL.String(), WorkflowId2) && strings.Contains(r.URL.String(), "/getworkflow/") {
+			fmt.Fprintf(w, Workflow2ScheduleJsonResponse)
+		}
 	}))
 	defer apiSvr.Close()
 	apiUrl, _ := url.Parse(apiSvr.URL)
@@ -210,13 +229,13 @@ func TestErrorGettingBinWorkflowIds(t *testing.T) {
 	r = mux.SetURLVars(r, vars)
 	httpClient := http.Client{}
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	handler := handler.CollectionHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
 
 	handler.Handle(w, r)
 
 	assert.Equal(t, w.Code, http.StatusInternalServerError)
-	assert.Contains(t, w.Body.String(), "fetching workflows of bins")
+	assert.Contains(t, w.Body.String(), "Something went wrong")
 }
 
 func TestErrorGettingWorkflowSchedules(t *testing.T) {
@@ -253,13 +272,13 @@ func TestErrorGettingWorkflowSchedules(t *testing.T) {
 	r = mux.SetURLVars(r, vars)
 	httpClient := http.Client{}
 	clock := clockwork.NewFakeClock()
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	handler := handler.CollectionHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
 
 	handler.Handle(w, r)
 
 	assert.Equal(t, w.Code, http.StatusInternalServerError)
-	assert.Contains(t, w.Body.String(), "fetching workflow schedule")
+	assert.Contains(t, w.Body.String(), "Something went wrong")
 }
 
 func TestNextCollectionDateForEachBin(t *testing.T) {
@@ -298,8 +317,8 @@ func TestNextCollectionDateForEachBin(t *testing.T) {
 	london, _ := time.LoadLocation("Europe/London")
 	now := time.Date(2023, 12, 15, 3, 19, 46, 72, london)
 	clock := clockwork.NewFakeClockAt(now)
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	handler := handler.CollectionHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
 
 	handler.Handle(w, r)
 
@@ -310,14 +329,19 @@ func TestNextCollectionDateForEachBin(t *testing.T) {
 			"Name": "29 ACACIA AVENUE",
 			"Bins": [
 				{
+					"Id": "bin1",
+					"TypeId": "5f96b6f8d1f4f500660f3058",
+					"Kind": "sack",
 					"Name": "Garbage can",
 					"Type": "garden",
-					"NextCollection": "2024-01-01T00:00:00Z"
+					"NextCollection": "2024-01-01"
 				},
 				{
+					"Id": "bin2",
+					"Kind": "unknown",
 					"Name": "Dumpster",
 					"Type": "unknown",
-					"NextCollection": "2024-01-02T00:00:00Z"
+					"NextCollection": "2024-01-02"
 				}
 			]
 		}`)
@@ -361,8 +385,8 @@ func TestOnlyFetchEachUniqueWorkflowScheduleOnce(t *testing.T) {
 	london, _ := time.LoadLocation("Europe/London")
 	now := time.Date(2023, 12, 15, 3, 19, 46, 72, london)
 	clock := clockwork.NewFakeClockAt(now)
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	handler := handler.CollectionHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
 
 	handler.Handle(w, r)
 
@@ -373,19 +397,88 @@ func TestOnlyFetchEachUniqueWorkflowScheduleOnce(t *testing.T) {
 			"Name": "29 ACACIA AVENUE",
 			"Bins": [
 				{
+					"Id": "bin1",
+					"TypeId": "5f96b6f8d1f4f500660f3058",
+					"Kind": "sack",
 					"Name": "Garbage can",
 					"Type": "garden",
-					"NextCollection": "2024-01-01T00:00:00Z"
+					"NextCollection": "2024-01-01"
 				},
 				{
+					"Id": "bin2",
+					"Kind": "unknown",
 					"Name": "Dumpster",
 					"Type": "unknown",
-					"NextCollection": "2024-01-01T00:00:00Z"
+					"NextCollection": "2024-01-01"
 				}
 			]
 		}`)
 }
 
+// Bins sharing a workflow must each wait for its schedule, rather than
+// returning without one because another bin has already started fetching it.
+func TestConcurrentRequestsWaitForSharedSchedule(t *testing.T) {
+	var bin1Collected, bin2Collected sync.Once
+	collected1 := make(chan struct{})
+	collected2 := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		<-collected1
+		<-collected2
+		close(release)
+	}()
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/getproperty/"):
+			fmt.Fprint(w, BinIdJsonResponse)
+		case strings.Contains(r.URL.Path, "/getbin/"+BinId1):
+			fmt.Fprint(w, Bin1TypeJsonResponse)
+		case strings.Contains(r.URL.Path, "/getbin/"+BinId2):
+			fmt.Fprint(w, Bin2TypeJsonResponse)
+		case strings.Contains(r.URL.Path, "/getcollection/"+BinId1):
+			fmt.Fprint(w, Bin1WorkflowIdJsonResponse)
+			bin1Collected.Do(func() { close(collected1) })
+		case strings.Contains(r.URL.Path, "/getcollection/"+BinId2):
+			// Same workflow as the first bin.
+			fmt.Fprint(w, Bin1WorkflowIdJsonResponse)
+			bin2Collected.Do(func() { close(collected2) })
+		case strings.Contains(r.URL.Path, "/getworkflow/"+WorkflowId1):
+			// Held until both bins know they need it.
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+			fmt.Fprint(w, Workflow1ScheduleJsonResponse)
+		}
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	london, _ := time.LoadLocation("Europe/London")
+	clock := clockwork.NewFakeClockAt(time.Date(2023, 12, 15, 3, 19, 46, 72, london))
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, Coalescer: &client.Coalescer{}}
+	h := handler.CollectionHandler{Client: binsClient}
+
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, 10)
+	for i := range responses {
+		r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+		r = mux.SetURLVars(r, map[string]string{"property_id": PropertyId})
+		responses[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.Handle(responses[i], r)
+		}()
+	}
+	wg.Wait()
+
+	for _, w := range responses {
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 2, strings.Count(w.Body.String(), `"NextCollection":"2024-01-01"`), w.Body.String())
+	}
+}
+
 func TestSkipBinWithNoNextCollection(t *testing.T) {
 	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(r.URL.String(), PropertyId) {
@@ -431,8 +524,8 @@ func TestSkipBinWithNoNextCollection(t *testing.T) {
 	london, _ := time.LoadLocation("Europe/London")
 	now := time.Date(2023, 12, 15, 3, 19, 46, 72, london)
 	clock := clockwork.NewFakeClockAt(now)
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	handler := handler.CollectionHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
 
 	handler.Handle(w, r)
 
@@ -443,9 +536,12 @@ func TestSkipBinWithNoNextCollection(t *testing.T) {
 			"Name": "29 ACACIA AVENUE",
 			"Bins": [
 				{
+					"Id": "bin1",
+					"TypeId": "5f96b6f8d1f4f500660f3058",
+					"Kind": "sack",
 					"Name": "Garbage can",
 					"Type": "garden",
-					"NextCollection": "2024-01-01T00:00:00Z"
+					"NextCollection": "2024-01-01"
 				}
 			]
 		}`)
@@ -498,8 +594,8 @@ func TestFetchTwiceWithoutCache(t *testing.T) {
 	london, _ := time.LoadLocation("Europe/London")
 	now := time.Date(2023, 12, 15, 3, 19, 46, 72, london)
 	clock := clockwork.NewFakeClockAt(now)
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	handler := handler.CollectionHandler{client, nil}
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
 
 	handler.Handle(w1, r1)
 	handler.Handle(w2, r2)
@@ -559,9 +655,9 @@ func TestFetchOnceWithCache(t *testing.T) {
 	london, _ := time.LoadLocation("Europe/London")
 	now := time.Date(2023, 12, 15, 3, 19, 46, 72, london)
 	clock := clockwork.NewFakeClockAt(now)
-	client := client.BinsClient{httpClient, clock, apiUrl, nil}
-	cache := expirable.NewLRU[string, interface{}](1024, nil, time.Minute*10)
-	handler := handler.CollectionHandler{client, cache}
+	cache, _ := client.NewMemoryCache(1024, clock)
+	client := client.BinsClient{HttpClient: httpClient, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client, Cache: cache}
 
 	handler.Handle(w1, r1)
 	handler.Handle(w2, r2)
@@ -573,3 +669,684 @@ func TestFetchOnceWithCache(t *testing.T) {
 		assert.Equal(t, v, 1)
 	}
 }
+
+func TestMergeSchedulesFromEveryWorkflow(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.String(), PropertyId) {
+			fmt.Fprintf(w, `{
+				"addressSummary": "`+Address+`",
+				"providerSpecificFields": {
+					"attributes_wasteContainersAssignableWasteContainers": "`+BinId1+`"
+				}
+			}`)
+		}
+		if strings.Contains(r.URL.String(), BinId1) && strings.Contains(r.URL.String(), "/getbin/") {
+			fmt.Fprintf(w, Bin1TypeJsonResponse)
+		}
+		if strings.Contains(r.URL.String(), BinId1) && strings.Contains(r.URL.String(), "/getcollection/") {
+			fmt.Fprintf(w, `{
+				"scheduleCodeWorkflowIDs": ["`+WorkflowId1+`", "`+WorkflowId2+`"]
+			}`)
+		}
+		if strings.Contains(r.URL.String(), WorkflowId1) && strings.Contains(r.URL.String(), "/getworkflow/") {
+			fmt.Fprintf(w, `{
+				"trigger": {
+					"dates": ["2024-01-08T09:00:00.000Z", "2024-01-15T09:00:00.000Z"]
+				}
+			}`)
+		}
+		if strings.Contains(r.URL.String(), WorkflowId2) && strings.Contains(r.URL.String(), "/getworkflow/") {
+			// Replacement collection brought forward, plus one the same as the regular round
+			fmt.Fprintf(w, `{
+				"trigger": {
+					"dates": ["2024-01-15T10:00:00.000Z", "2024-01-06T10:00:00.000Z"]
+				}
+			}`)
+		}
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl+"schedule", nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	london, _ := time.LoadLocation("Europe/London")
+	now := time.Date(2023, 12, 15, 3, 19, 46, 72, london)
+	clock := clockwork.NewFakeClockAt(now)
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	collectionHandler := handler.CollectionHandler{Client: client}
+	scheduleHandler := handler.ScheduleHandler{Client: client}
+
+	collectionHandler.Handle(w, r)
+
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.JSONEq(t, w.Body.String(), `
+		{
+			"PropertyId": "property_id",
+			"Name": "29 ACACIA AVENUE",
+			"Bins": [
+				{
+					"Id": "bin1",
+					"TypeId": "5f96b6f8d1f4f500660f3058",
+					"Kind": "sack",
+					"Name": "Garbage can",
+					"Type": "garden",
+					"NextCollection": "2024-01-06"
+				}
+			]
+		}`)
+
+	w = httptest.NewRecorder()
+	scheduleHandler.Handle(w, r)
+
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.JSONEq(t, w.Body.String(), `
+		{
+			"PropertyId": "property_id",
+			"Name": "29 ACACIA AVENUE",
+			"Bins": [
+				{
+					"Name": "Garbage can",
+					"Type": "garden",
+					"Collections": [
+						"2024-01-06",
+						"2024-01-08",
+						"2024-01-15"
+					]
+				}
+			]
+		}`)
+}
+
+func TestAbandonOutstandingCallsAfterError(t *testing.T) {
+	done := make(chan struct{})
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.String(), PropertyId) {
+			fmt.Fprintf(w, BinIdJsonResponse)
+			return
+		}
+		if strings.Contains(r.URL.String(), BinId1) && strings.Contains(r.URL.String(), "/getbin/") {
+			http.Error(w, "can't get bin type", http.StatusTeapot)
+			return
+		}
+		// Everything else hangs until the caller gives up
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer apiSvr.Close()
+	defer close(done)
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Something went wrong")
+}
+
+func TestStopWhenRequestCancelled(t *testing.T) {
+	done := make(chan struct{})
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer apiSvr.Close()
+	defer close(done)
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
+	time.AfterFunc(time.Millisecond*10, cancel)
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Something went wrong")
+}
+
+func TestServiceUnavailableWhenCircuitOpen(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	clock := clockwork.NewFakeClock()
+	breaker := &client.CircuitBreaker{Threshold: 1, Cooldown: time.Second * 90, Clock: clock}
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, Breaker: breaker}
+	handler := handler.CollectionHandler{Client: client}
+
+	r1, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w1 := httptest.NewRecorder()
+	handler.Handle(w1, mux.SetURLVars(r1, vars))
+	clock.Advance(time.Millisecond * 500)
+	r2, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w2 := httptest.NewRecorder()
+	handler.Handle(w2, mux.SetURLVars(r2, vars))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w1.Code)
+	assert.Equal(t, http.StatusServiceUnavailable, w2.Code)
+	assert.Equal(t, "90", w2.Header().Get("Retry-After"))
+	assert.Contains(t, w2.Body.String(), "try again later")
+}
+
+func TestServeStaleDataWhenApiFails(t *testing.T) {
+	var broken atomic.Bool
+	working := propertyApiServer(make(map[string]int))
+	defer working.Close()
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if broken.Load() {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		working.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	london, _ := time.LoadLocation("Europe/London")
+	now := time.Date(2023, 12, 15, 3, 19, 46, 72, london)
+	clock := clockwork.NewFakeClockAt(now)
+	staleCache, _ := lru.New[string, interface{}](1024)
+	cache, _ := client.NewMemoryCache(1024, clock)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, StaleCache: staleCache}
+	handler := handler.CollectionHandler{Client: binsClient, Cache: cache, CacheTTLs: client.TTLs{Responses: time.Minute * 10}}
+
+	r1, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w1 := httptest.NewRecorder()
+	handler.Handle(w1, mux.SetURLVars(r1, vars))
+	broken.Store(true)
+	clock.Advance(time.Minute * 20)
+	r2, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w2 := httptest.NewRecorder()
+	handler.Handle(w2, mux.SetURLVars(r2, vars))
+
+	assert.Equal(t, http.StatusOK, w1.Code)
+	assert.Empty(t, w1.Header().Get("Warning"))
+	assert.NotContains(t, w1.Body.String(), "Stale")
+	assert.Equal(t, http.StatusOK, w2.Code)
+	assert.Equal(t, `110 - "Response is Stale"`, w2.Header().Get("Warning"))
+	assert.Equal(t, "1200", w2.Header().Get("X-Data-Age"))
+	assert.JSONEq(t, w2.Body.String(), `
+		{
+			"PropertyId": "property_id",
+			"Name": "29 ACACIA AVENUE",
+			"Bins": [
+				{
+					"Id": "bin1",
+					"TypeId": "5f96b6f8d1f4f500660f3058",
+					"Kind": "sack",
+					"Name": "Garbage can",
+					"Type": "garden",
+					"NextCollection": "2024-01-01"
+				},
+				{
+					"Id": "bin2",
+					"Kind": "unknown",
+					"Name": "Dumpster",
+					"Type": "unknown",
+					"NextCollection": "2024-01-02"
+				}
+			],
+			"Stale": true
+		}`)
+	_, found := client.CachedResponses.Get(cache, RequestUrl)
+	assert.False(t, found)
+}
+
+func TestResponseCacheExpiresAtMidnight(t *testing.T) {
+	fetches := make(map[string]int)
+	apiSvr := propertyApiServer(fetches)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	london, _ := time.LoadLocation("Europe/London")
+	now := time.Date(2024, 1, 1, 23, 55, 0, 0, london)
+	clock := clockwork.NewFakeClockAt(now)
+	cache, _ := client.NewMemoryCache(1024, clock)
+	ttls := client.TTLs{Schedules: time.Hour, Responses: time.Minute * 15}
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, Cache: cache, CacheTTLs: ttls}
+	handler := handler.CollectionHandler{Client: client, Cache: cache, CacheTTLs: ttls}
+
+	r1, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w1 := httptest.NewRecorder()
+	handler.Handle(w1, mux.SetURLVars(r1, vars))
+	clock.Advance(time.Minute * 10)
+	r2, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w2 := httptest.NewRecorder()
+	handler.Handle(w2, mux.SetURLVars(r2, vars))
+
+	assert.Contains(t, w1.Body.String(), `"NextCollection":"2024-01-01"`)
+	assert.NotContains(t, w2.Body.String(), "2024-01-01")
+	assert.Contains(t, w2.Body.String(), `"NextCollection":"2025-07-01"`)
+	assert.Equal(t, 1, fetches["/alloywastepages/getworkflow/"+WorkflowId1])
+}
+
+func TestNextCollectionLegacyDates(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	london, _ := time.LoadLocation("Europe/London")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, london)
+	clock := clockwork.NewFakeClockAt(now)
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client, LegacyDates: true}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `
+		{
+			"PropertyId": "property_id",
+			"Name": "29 ACACIA AVENUE",
+			"Bins": [
+				{
+					"Id": "bin1",
+					"TypeId": "5f96b6f8d1f4f500660f3058",
+					"Kind": "sack",
+					"Name": "Garbage can",
+					"Type": "garden",
+					"NextCollection": "2024-01-01T00:00:00Z"
+				},
+				{
+					"Id": "bin2",
+					"Kind": "unknown",
+					"Name": "Dumpster",
+					"Type": "unknown",
+					"NextCollection": "2024-01-02T00:00:00Z"
+				}
+			]
+		}`, w.Body.String())
+}
+
+func TestBadGatewayWhenApiResponseChanges(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"addressSummary": "foo", "containers": "bar"}`)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), "attributes_wasteContainersAssignableWasteContainers")
+}
+
+func TestProblemJsonWhenPropertyHasNoBins(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `
+			{
+				"addressSummary": "foo",
+				"providerSpecificFields": {
+					"attributes_wasteContainersAssignableWasteContainers": ""
+				}
+			}
+		`)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, "/property/"+PropertyId, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `
+		{
+			"type": "/problems/not-found",
+			"title": "Not Found",
+			"status": 404,
+			"detail": "Bin IDs not found for property",
+			"instance": "/property/property_id"
+		}`, w.Body.String())
+}
+
+func TestServiceUnavailableWhenApiRateLimited(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "120", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"type":"/problems/upstream-rate-limited"`)
+	assert.NotContains(t, w.Body.String(), "slow down")
+}
+
+func TestServiceUnavailableWhenApiUnreachable(t *testing.T) {
+	apiSvr := httptest.NewServer(http.NotFoundHandler())
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	apiSvr.Close()
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"type":"/problems/upstream-unavailable"`)
+	assert.NotContains(t, w.Body.String(), apiUrl.Host)
+}
+
+func TestContainerDetailsForEachBin(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/getproperty/"):
+			fmt.Fprintf(w, `
+				{
+					"addressSummary": "Estate",
+					"providerSpecificFields": {
+						"attributes_wasteContainersAssignableWasteContainers": "bin1"
+					}
+				}`)
+		case strings.Contains(r.URL.Path, "/getbin/"):
+			fmt.Fprintf(w, `{"subTitle": "ES_Refuse 1100L", "binType": "abc"}`)
+		case strings.Contains(r.URL.Path, "/getcollection/"):
+			fmt.Fprintf(w, Bin1WorkflowIdJsonResponse)
+		case strings.Contains(r.URL.Path, "/getworkflow/"):
+			fmt.Fprintf(w, Workflow1ScheduleJsonResponse)
+		}
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	london, _ := time.LoadLocation("Europe/London")
+	clock := clockwork.NewFakeClockAt(time.Date(2023, 12, 15, 3, 19, 46, 72, london))
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `
+		{
+			"PropertyId": "property_id",
+			"Name": "Estate",
+			"Bins": [
+				{
+					"Id": "bin1",
+					"Name": "ES_Refuse 1100L",
+					"Type": "rubbish",
+					"TypeId": "abc",
+					"Kind": "communal",
+					"Capacity": 1100,
+					"Communal": true,
+					"NextCollection": "2024-01-01"
+				}
+			]
+		}`, w.Body.String())
+}
+
+func TestTradeBin(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/getproperty/"):
+			fmt.Fprintf(w, `
+				{
+					"addressSummary": "Shop",
+					"providerSpecificFields": {
+						"attributes_wasteContainersAssignableWasteContainers": "bin1"
+					}
+				}`)
+		case strings.Contains(r.URL.Path, "/getbin/"):
+			fmt.Fprintf(w, `{"subTitle": "Eurobin", "binType": "619f87d15c9f9c016ce81494"}`)
+		case strings.Contains(r.URL.Path, "/getcollection/"):
+			fmt.Fprintf(w, Bin1WorkflowIdJsonResponse)
+		case strings.Contains(r.URL.Path, "/getworkflow/"):
+			fmt.Fprintf(w, Workflow1ScheduleJsonResponse)
+		}
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	r = mux.SetURLVars(r, map[string]string{"property_id": PropertyId})
+	london, _ := time.LoadLocation("Europe/London")
+	clock := clockwork.NewFakeClockAt(time.Date(2023, 12, 15, 3, 19, 46, 72, london))
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `
+		{
+			"PropertyId": "property_id",
+			"Name": "Shop",
+			"Bins": [
+				{
+					"Id": "bin1",
+					"Name": "Eurobin",
+					"Type": "rubbish",
+					"TypeId": "619f87d15c9f9c016ce81494",
+					"Kind": "wheelie_bin",
+					"Capacity": 1100,
+					"Trade": true,
+					"NextCollection": "2024-01-01"
+				}
+			]
+		}`, w.Body.String())
+}
+
+// Serves a property with a recycling sack and a food caddy collected on the
+// same days, and a garden waste bin collected on other days.
+func collectionsApiServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/getproperty/"):
+			fmt.Fprintf(w, `
+				{
+					"addressSummary": "29 ACACIA AVENUE",
+					"providerSpecificFields": {
+						"attributes_wasteContainersAssignableWasteContainers": "food,garden,recycling"
+					}
+				}`)
+		case strings.HasSuffix(r.URL.Path, "/getbin/food"):
+			fmt.Fprintf(w, `{"subTitle": "Food caddy", "binType": "5f96b455e36673006420c529"}`)
+		case strings.HasSuffix(r.URL.Path, "/getbin/garden"):
+			fmt.Fprintf(w, `{"subTitle": "Garden bin", "binType": "5f96b596e36673006420c665"}`)
+		case strings.HasSuffix(r.URL.Path, "/getbin/recycling"):
+			fmt.Fprintf(w, `{"subTitle": "Recycling sack", "binType": "5f89bea126b55500675f4d08"}`)
+		case strings.HasSuffix(r.URL.Path, "/getcollection/garden"):
+			fmt.Fprintf(w, Bin2WorkflowIdJsonResponse)
+		case strings.Contains(r.URL.Path, "/getcollection/"):
+			fmt.Fprintf(w, Bin1WorkflowIdJsonResponse)
+		case strings.Contains(r.URL.Path, "/getworkflow/"+WorkflowId1):
+			fmt.Fprintf(w, Workflow1ScheduleJsonResponse)
+		case strings.Contains(r.URL.Path, "/getworkflow/"+WorkflowId2):
+			fmt.Fprintf(w, Workflow2ScheduleJsonResponse)
+		}
+	}))
+}
+
+func TestGroupBinsIntoCollections(t *testing.T) {
+	apiSvr := collectionsApiServer()
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, "/property/property_id?view=collections", nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	london, _ := time.LoadLocation("Europe/London")
+	clock := clockwork.NewFakeClockAt(time.Date(2023, 12, 15, 3, 19, 46, 72, london))
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.CollectionHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `
+		{
+			"PropertyId": "property_id",
+			"Name": "29 ACACIA AVENUE",
+			"Bins": [
+				{"Id": "food", "Name": "Food caddy", "Type": "food", "TypeId": "5f96b455e36673006420c529", "Kind": "caddy", "NextCollection": "2024-01-01"},
+				{"Id": "garden", "Name": "Garden bin", "Type": "garden", "TypeId": "5f96b596e36673006420c665", "Kind": "wheelie_bin", "NextCollection": "2024-01-02"},
+				{"Id": "recycling", "Name": "Recycling sack", "Type": "recycling", "TypeId": "5f89bea126b55500675f4d08", "Kind": "sack", "NextCollection": "2024-01-01"}
+			],
+			"Collections": [
+				{
+					"Date": "2024-01-01",
+					"Summary": "Recycling and food waste",
+					"Types": ["recycling", "food"],
+					"Bins": [
+						{"Id": "food", "Name": "Food caddy", "Type": "food", "TypeId": "5f96b455e36673006420c529", "Kind": "caddy", "NextCollection": "2024-01-01"},
+						{"Id": "recycling", "Name": "Recycling sack", "Type": "recycling", "TypeId": "5f89bea126b55500675f4d08", "Kind": "sack", "NextCollection": "2024-01-01"}
+					]
+				},
+				{
+					"Date": "2024-01-02",
+					"Summary": "Garden waste",
+					"Types": ["garden"],
+					"Bins": [
+						{"Id": "garden", "Name": "Garden bin", "Type": "garden", "TypeId": "5f96b596e36673006420c665", "Kind": "wheelie_bin", "NextCollection": "2024-01-02"}
+					]
+				}
+			]
+		}`, w.Body.String())
+}
+
+func TestNoCollectionsByDefault(t *testing.T) {
+	apiSvr := collectionsApiServer()
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	for _, target := range []string{"/property/property_id", "/property/property_id?view=bins"} {
+		r, _ := http.NewRequest(http.MethodGet, target, nil)
+		w := httptest.NewRecorder()
+		vars := map[string]string{
+			"property_id": PropertyId,
+		}
+		r = mux.SetURLVars(r, vars)
+		clock := clockwork.NewFakeClock()
+		client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+		handler := handler.CollectionHandler{Client: client}
+
+		handler.Handle(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "Collections", target)
+	}
+}
+
+func TestBadView(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/property/property_id?view=calendar", nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: &url.URL{}}
+	handler := handler.CollectionHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "view must be bins or collections")
+}
+
+func TestCollections(t *testing.T) {
+	apiSvr := collectionsApiServer()
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	london, _ := time.LoadLocation("Europe/London")
+	clock := clockwork.NewFakeClockAt(time.Date(2023, 12, 31, 18, 0, 0, 0, london))
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	h := handler.CollectionHandler{Client: binsClient}
+
+	p, err := h.Collections(context.Background(), PropertyId)
+
+	schedule := []client.Date{client.NewDate(2024, time.January, 1), client.NewDate(2025, time.July, 1)}
+	food := handler.CollectedBin{Id: "food", Name: "Food caddy", Type: client.Food, Schedule: schedule}
+	recycling := handler.CollectedBin{Id: "recycling", Name: "Recycling sack", Type: client.Recycling, Schedule: schedule}
+	assert.Nil(t, err)
+	assert.Equal(t, PropertyId, p.Id)
+	assert.Equal(t, "29 ACACIA AVENUE", p.Name)
+	assert.Contains(t, p.Bins, food)
+	assert.Contains(t, p.Bins, recycling)
+	assert.Equal(t, handler.Collection{
+		Date:    client.NewDate(2024, time.January, 1),
+		Summary: "Recycling and food waste",
+		Types:   []client.RefuseType{client.Recycling, client.Food},
+		Bins:    []handler.CollectedBin{food, recycling},
+	}, p.Days[0])
+	for _, day := range p.Days[1:] {
+		assert.True(t, day.Date.After(p.Days[0].Date))
+	}
+}
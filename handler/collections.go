@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+)
+
+// Bins that are collected on the same day.
+type collectionDay struct {
+	Date client.Date
+	Bins []propertyBin
+}
+
+// Groups bins by the days they are collected, soonest first. Only the first
+// limit collections of each bin are counted, or all of them if limit is zero.
+func collectionDays(bins []propertyBin, limit int) []collectionDay {
+	var days []collectionDay
+	for _, b := range bins {
+		schedule := b.Schedule
+		if limit > 0 && len(schedule) > limit {
+			schedule = schedule[:limit]
+		}
+		for _, date := range schedule {
+			i, found := slices.BinarySearchFunc(days, date, func(d collectionDay, date client.Date) int {
+				return d.Date.Compare(date)
+			})
+			if !found {
+				days = slices.Insert(days, i, collectionDay{Date: date})
+			}
+			days[i].Bins = append(days[i].Bins, b)
+		}
+	}
+	return days
+}
+
+// The order types of refuse are listed in summaries, most important first.
+var summaryOrder = []client.RefuseType{
+	client.Rubbish,
+	client.Recycling,
+	client.Food,
+	client.Garden,
+	client.Glass,
+	client.Textiles,
+	client.Bulky,
+	client.Electricals,
+	client.UndefinedRefuseType,
+}
+
+func refusePhrase(t client.RefuseType) string {
+	switch t {
+	case client.Food:
+		return "food waste"
+	case client.Garden:
+		return "garden waste"
+	case client.Bulky:
+		return "bulky waste"
+	case client.UndefinedRefuseType:
+		return "other waste"
+	}
+	return t.String()
+}
+
+// The types of refuse collected from bins, in the order they appear in summaries.
+func refuseTypes(bins []propertyBin) []client.RefuseType {
+	var types []client.RefuseType
+	for _, t := range summaryOrder {
+		if slices.ContainsFunc(bins, func(b propertyBin) bool { return b.Type.Type == t }) {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// Describes what is collected from bins for people, such as "Recycling and
+// food waste".
+func summarise(bins []propertyBin) string {
+	var phrases []string
+	for _, t := range refuseTypes(bins) {
+		phrases = append(phrases, refusePhrase(t))
+	}
+	if len(phrases) == 0 {
+		return ""
+	}
+	summary := phrases[len(phrases)-1]
+	if len(phrases) > 1 {
+		summary = strings.Join(phrases[:len(phrases)-1], ", ") + " and " + summary
+	}
+	return capitalize(summary)
+}
+
+// A property's bins and the days they are collected, worked out the same way
+// as for the property endpoint, for reminding people about collections.
+type PropertyCollections struct {
+	Id   string
+	Name string
+	Bins []CollectedBin
+	// Soonest first.
+	Days []Collection
+}
+
+type CollectedBin struct {
+	Id       string
+	Name     string
+	Type     client.RefuseType
+	Schedule []client.Date
+}
+
+// What is collected from a property on one day.
+type Collection struct {
+	Date client.Date
+	// Such as "Recycling and food waste".
+	Summary string
+	// In the order they appear in Summary.
+	Types []client.RefuseType
+	Bins  []CollectedBin
+}
+
+func collectedBins(bins []propertyBin) []CollectedBin {
+	var res []CollectedBin
+	for _, b := range bins {
+		res = append(res, CollectedBin{Id: b.Id, Name: b.Type.Name, Type: b.Type.Type, Schedule: b.Schedule})
+	}
+	return res
+}
+
+func (h *CollectionHandler) Collections(ctx context.Context, propertyId string) (PropertyCollections, error) {
+	p, err := fetchProperty(ctx, h.Client, propertyId)
+	if err != nil {
+		return PropertyCollections{}, err
+	}
+	res := PropertyCollections{Id: p.Id, Name: p.Name, Bins: collectedBins(p.Bins)}
+	for _, day := range collectionDays(p.Bins, 0) {
+		res.Days = append(res.Days, Collection{
+			Date:    day.Date,
+			Summary: summarise(day.Bins),
+			Types:   refuseTypes(day.Bins),
+			Bins:    collectedBins(day.Bins),
+		})
+	}
+	return res, nil
+}
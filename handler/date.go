@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+)
+
+// A collection date in a response. Dates used to be given as timestamps at
+// midnight in London, and some clients may still expect that.
+type responseDate struct {
+	date   client.Date
+	legacy bool
+}
+
+func (d responseDate) MarshalJSON() ([]byte, error) {
+	if !d.legacy {
+		return json.Marshal(d.date)
+	}
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(d.date.In(london))
+}
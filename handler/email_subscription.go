@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/mail"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+)
+
+// Shown to people who follow the links in emails, rather than JSON.
+var emailPage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Hackney Bindicator</title>
+<link rel="stylesheet" href="/static/style.css">
+</head>
+<body>
+<p>{{.Message}}</p>
+{{- if .Button}}
+<form method="post">
+<button type="submit">{{.Button}}</button>
+</form>
+{{- end}}
+</body>
+</html>
+`))
+
+// Lets people ask for an email the evening before each collection at a
+// property, confirm their address, and unsubscribe.
+type EmailSubscriptionHandler struct {
+	Client    client.BinsClient
+	Reminders *reminder.EmailReminders
+}
+
+func writePage(w http.ResponseWriter, status int, message, button string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	emailPage.Execute(w, struct{ Message, Button string }{message, button})
+}
+
+// Always says a confirmation email has been sent, whether or not the address
+// had already subscribed, or was sent one too recently to send another.
+func (h *EmailSubscriptionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PropertyId string
+		Email      string
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "Body must be JSON with a PropertyId and an Email")
+		return
+	}
+	if req.PropertyId == "" {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "PropertyId must not be empty")
+		return
+	}
+	// Only a bare address, so nobody can add headers or other recipients.
+	address, err := mail.ParseAddress(req.Email)
+	if err != nil || address.Name != "" || address.Address != req.Email {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "Email must be an email address")
+		return
+	}
+	binIds, err := h.Client.GetBinIdsContext(r.Context(), req.PropertyId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	err = h.Reminders.Subscribe(r.Context(), req.PropertyId, binIds.Name, address.Address)
+	if errors.Is(err, reminder.ErrFull) {
+		writeProblem(w, r, problemTooManySubscriptions, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		writeProblem(w, r, problemInternal, http.StatusInternalServerError, "Could not send the confirmation email")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Like Unsubscribe, following the link only asks whether to confirm, so that
+// mail systems checking links don't confirm addresses nobody asked for.
+func (h *EmailSubscriptionHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writePage(w, http.StatusOK, "Get emailed the evening before bins are collected?", "Confirm")
+		return
+	}
+	sub, err := h.Reminders.Confirm(r.URL.Query().Get("token"))
+	switch {
+	case errors.Is(err, reminder.ErrBadLink):
+		writePage(w, http.StatusNotFound, err.Error(), "")
+	case err != nil:
+		writePage(w, http.StatusInternalServerError, "Something went wrong, try again later", "")
+	default:
+		writePage(w, http.StatusOK, "You will be emailed the evening before bins are collected from "+sub.Name+".", "")
+	}
+}
+
+// Following the link only asks whether to unsubscribe, as some mail systems
+// follow links to check them. The button, and mail clients' own unsubscribe
+// buttons, POST to the same URL.
+func (h *EmailSubscriptionHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writePage(w, http.StatusOK, "Stop emailing bin collection reminders?", "Unsubscribe")
+		return
+	}
+	err := h.Reminders.Unsubscribe(r.URL.Query().Get("token"))
+	switch {
+	case errors.Is(err, reminder.ErrBadLink):
+		writePage(w, http.StatusNotFound, err.Error(), "")
+	case err != nil:
+		writePage(w, http.StatusInternalServerError, "Something went wrong, try again later", "")
+	default:
+		writePage(w, http.StatusOK, "You won't get any more reminders.", "")
+	}
+}
@@ -0,0 +1,335 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/handler"
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingMailer struct {
+	sent []reminder.Message
+	err  error
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg reminder.Message) error {
+	m.sent = append(m.sent, msg)
+	return m.err
+}
+
+var tokenPattern = regexp.MustCompile(`\?token=(\S+)`)
+
+func subscribe(t *testing.T, h handler.EmailSubscriptionHandler, mailer *recordingMailer) string {
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions/email", strings.NewReader(`
+		{"PropertyId": "property_id", "Email": "resident@example.com"}`))
+	h.Create(httptest.NewRecorder(), r)
+	match := tokenPattern.FindStringSubmatch(mailer.sent[len(mailer.sent)-1].Text)
+	return match[1]
+}
+
+func TestCreateEmailSubscription(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.EmailSubscription]("")
+	mailer := &recordingMailer{}
+	reminders := &reminder.EmailReminders{
+		Store:   store,
+		Mailer:  mailer,
+		Key:     []byte("key"),
+		BaseUrl: "https://bins.example.com",
+		Clock:   clock,
+	}
+	h := handler.EmailSubscriptionHandler{Client: binsClient, Reminders: reminders}
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions/email", strings.NewReader(`
+		{"PropertyId": "property_id", "Email": "resident@example.com"}`))
+	w := httptest.NewRecorder()
+
+	h.Create(w, r)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Len(t, mailer.sent, 1)
+	assert.Equal(t, "resident@example.com", mailer.sent[0].To)
+	assert.Contains(t, mailer.sent[0].Text, "29 ACACIA AVENUE")
+	subs := store.All()
+	assert.Len(t, subs, 1)
+	assert.Equal(t, PropertyId, subs[0].PropertyId)
+	assert.Equal(t, "29 ACACIA AVENUE", subs[0].Name)
+	assert.False(t, subs[0].Confirmed)
+}
+
+func TestCreateEmailSubscriptionBadRequests(t *testing.T) {
+	tests := map[string]string{
+		`not json`: "Body must be JSON",
+		`{"PropertyId": "property_id", "Email": "resident@example.com", "Extra": 1}`: "Body must be JSON",
+		`{"Email": "resident@example.com"}`:                                          "PropertyId must not be empty",
+		`{"PropertyId": "property_id"}`:                                              "Email must be",
+		`{"PropertyId": "property_id", "Email": "resident"}`:                         "Email must be",
+		`{"PropertyId": "property_id", "Email": "Someone <resident@example.com>"}`:   "Email must be",
+		`{"PropertyId": "property_id", "Email": "a@example.com, b@example.com"}`:     "Email must be",
+	}
+	for body, message := range tests {
+		apiSvr := propertyApiServer(make(map[string]int))
+		defer apiSvr.Close()
+		apiUrl, _ := url.Parse(apiSvr.URL)
+		clock := clockwork.NewFakeClock()
+		binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+		store, _ := reminder.NewStore[reminder.EmailSubscription]("")
+		mailer := &recordingMailer{}
+		reminders := &reminder.EmailReminders{
+			Store:   store,
+			Mailer:  mailer,
+			Key:     []byte("key"),
+			BaseUrl: "https://bins.example.com",
+			Clock:   clock,
+		}
+		h := handler.EmailSubscriptionHandler{Client: binsClient, Reminders: reminders}
+		r, _ := http.NewRequest(http.MethodPost, "/subscriptions/email", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		h.Create(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), message, body)
+		assert.Empty(t, store.All())
+		assert.Empty(t, mailer.sent)
+	}
+}
+
+func TestCreateEmailSubscriptionMailerFails(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.EmailSubscription]("")
+	mailer := &recordingMailer{}
+	reminders := &reminder.EmailReminders{
+		Store:   store,
+		Mailer:  mailer,
+		Key:     []byte("key"),
+		BaseUrl: "https://bins.example.com",
+		Clock:   clock,
+	}
+	h := handler.EmailSubscriptionHandler{Client: binsClient, Reminders: reminders}
+	mailer.err = errors.New("relay down")
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions/email", strings.NewReader(`
+		{"PropertyId": "property_id", "Email": "resident@example.com"}`))
+	w := httptest.NewRecorder()
+
+	h.Create(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Could not send the confirmation email")
+	assert.NotContains(t, w.Body.String(), "relay down")
+}
+
+func TestCreateEmailSubscriptionWhenFull(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.EmailSubscription]("")
+	mailer := &recordingMailer{}
+	reminders := &reminder.EmailReminders{
+		Store:   store,
+		Mailer:  mailer,
+		Key:     []byte("key"),
+		BaseUrl: "https://bins.example.com",
+		Clock:   clock,
+	}
+	h := handler.EmailSubscriptionHandler{Client: binsClient, Reminders: reminders}
+	store.MaxItems = 1
+	store.Put("other", reminder.EmailSubscription{Id: "other"})
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions/email", strings.NewReader(`
+		{"PropertyId": "property_id", "Email": "resident@example.com"}`))
+	w := httptest.NewRecorder()
+
+	h.Create(w, r)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "/problems/too-many-subscriptions")
+	assert.Empty(t, mailer.sent)
+}
+
+func TestConfirmLinkAsksFirst(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.EmailSubscription]("")
+	mailer := &recordingMailer{}
+	reminders := &reminder.EmailReminders{
+		Store:   store,
+		Mailer:  mailer,
+		Key:     []byte("key"),
+		BaseUrl: "https://bins.example.com",
+		Clock:   clock,
+	}
+	h := handler.EmailSubscriptionHandler{Client: binsClient, Reminders: reminders}
+	token := subscribe(t, h, mailer)
+	r, _ := http.NewRequest(http.MethodGet, reminder.ConfirmPath+"?token="+token, nil)
+	w := httptest.NewRecorder()
+
+	h.Confirm(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<form method="post">`)
+	assert.False(t, store.All()[0].Confirmed)
+}
+
+func TestConfirmEmailSubscription(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.EmailSubscription]("")
+	mailer := &recordingMailer{}
+	reminders := &reminder.EmailReminders{
+		Store:   store,
+		Mailer:  mailer,
+		Key:     []byte("key"),
+		BaseUrl: "https://bins.example.com",
+		Clock:   clock,
+	}
+	h := handler.EmailSubscriptionHandler{Client: binsClient, Reminders: reminders}
+	token := subscribe(t, h, mailer)
+	r, _ := http.NewRequest(http.MethodPost, reminder.ConfirmPath+"?token="+token, nil)
+	w := httptest.NewRecorder()
+
+	h.Confirm(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "29 ACACIA AVENUE")
+	assert.True(t, store.All()[0].Confirmed)
+}
+
+func TestConfirmEmailSubscriptionBadToken(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.EmailSubscription]("")
+	mailer := &recordingMailer{}
+	reminders := &reminder.EmailReminders{
+		Store:   store,
+		Mailer:  mailer,
+		Key:     []byte("key"),
+		BaseUrl: "https://bins.example.com",
+		Clock:   clock,
+	}
+	h := handler.EmailSubscriptionHandler{Client: binsClient, Reminders: reminders}
+	subscribe(t, h, mailer)
+	r, _ := http.NewRequest(http.MethodPost, reminder.ConfirmPath+"?token=nonsense", nil)
+	w := httptest.NewRecorder()
+
+	h.Confirm(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "not valid")
+	assert.False(t, store.All()[0].Confirmed)
+}
+
+func TestUnsubscribeLinkAsksFirst(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.EmailSubscription]("")
+	mailer := &recordingMailer{}
+	reminders := &reminder.EmailReminders{
+		Store:   store,
+		Mailer:  mailer,
+		Key:     []byte("key"),
+		BaseUrl: "https://bins.example.com",
+		Clock:   clock,
+	}
+	h := handler.EmailSubscriptionHandler{Client: binsClient, Reminders: reminders}
+	store.Put("abc", reminder.EmailSubscription{Id: "abc", Confirmed: true})
+	r, _ := http.NewRequest(http.MethodGet, reminder.UnsubscribePath+"?token=abc.whatever", nil)
+	w := httptest.NewRecorder()
+
+	h.Unsubscribe(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<form method="post">`)
+	assert.Len(t, store.All(), 1)
+}
+
+func TestUnsubscribe(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.EmailSubscription]("")
+	mailer := &recordingMailer{}
+	reminders := &reminder.EmailReminders{
+		Store:   store,
+		Mailer:  mailer,
+		Key:     []byte("key"),
+		BaseUrl: "https://bins.example.com",
+		Clock:   clock,
+	}
+	h := handler.EmailSubscriptionHandler{Client: binsClient, Reminders: reminders}
+	h.Reminders.Confirm(subscribe(t, h, mailer))
+	h.Reminders.Lookup = func(ctx context.Context, propertyId string, date client.Date) (reminder.Collection, bool, error) {
+		return reminder.Collection{Types: []string{"rubbish"}}, true, nil
+	}
+	h.Reminders.Send(context.Background(), h.Client.Clock.Now())
+	unsubscribe := mailer.sent[len(mailer.sent)-1].Headers["List-Unsubscribe"]
+	target, _ := url.Parse(strings.Trim(unsubscribe, "<>"))
+	// As sent by mail clients' own unsubscribe buttons.
+	r, _ := http.NewRequest(http.MethodPost, target.RequestURI(), strings.NewReader("List-Unsubscribe=One-Click"))
+	w := httptest.NewRecorder()
+
+	h.Unsubscribe(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "any more reminders")
+	assert.Empty(t, store.All())
+}
+
+func TestUnsubscribeBadToken(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.EmailSubscription]("")
+	mailer := &recordingMailer{}
+	reminders := &reminder.EmailReminders{
+		Store:   store,
+		Mailer:  mailer,
+		Key:     []byte("key"),
+		BaseUrl: "https://bins.example.com",
+		Clock:   clock,
+	}
+	h := handler.EmailSubscriptionHandler{Client: binsClient, Reminders: reminders}
+	confirm := subscribe(t, h, mailer)
+	r, _ := http.NewRequest(http.MethodPost, reminder.UnsubscribePath+"?token="+confirm, nil)
+	w := httptest.NewRecorder()
+
+	h.Unsubscribe(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Len(t, store.All(), 1)
+}
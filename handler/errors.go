@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+)
+
+// Identifies each kind of problem in error responses. Listed in the README.
+const (
+	problemInvalidInput          = "/problems/invalid-input"
+	problemNotFound              = "/problems/not-found"
+	problemUnauthorized          = "/problems/unauthorized"
+	problemTooManySubscriptions  = "/problems/too-many-subscriptions"
+	problemRateLimited           = "/problems/rate-limited"
+	problemUpstreamUnavailable   = "/problems/upstream-unavailable"
+	problemUpstreamRateLimited   = "/problems/upstream-rate-limited"
+	problemUpstreamSchemaChanged = "/problems/upstream-schema-changed"
+	problemInternal              = "about:blank"
+)
+
+// An error response, as described by RFC 7807.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// Sends a problem+json response describing what went wrong.
+func writeProblem(w http.ResponseWriter, r *http.Request, typ string, status int, detail string) {
+	res, err := json.Marshal(problem{
+		Type:     typ,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+	if err != nil {
+		http.Error(w, detail, status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(res)
+}
+
+func setRetryAfter(w http.ResponseWriter, after time.Duration) {
+	if after <= 0 {
+		return
+	}
+	seconds := max(int(math.Ceil(after.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
+
+// Sends the right status code and problem type for an error from the client.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var open client.CircuitOpenError
+	var schema client.ErrUpstreamSchema
+	var status client.StatusError
+	switch {
+	case errors.As(err, &open):
+		setRetryAfter(w, open.RetryAfter)
+		writeProblem(w, r, problemUpstreamUnavailable, http.StatusServiceUnavailable, err.Error())
+	case errors.As(err, &schema):
+		// Most likely the Council have changed their API, so someone needs
+		// to update the client to match.
+		log.Println("upstream schema changed:", err)
+		writeProblem(w, r, problemUpstreamSchemaChanged, http.StatusBadGateway, err.Error())
+	case errors.Is(err, client.ErrRateLimited):
+		if errors.As(err, &status) {
+			setRetryAfter(w, status.RetryAfter)
+		}
+		writeProblem(w, r, problemUpstreamRateLimited, http.StatusServiceUnavailable,
+			"The Council's API is limiting how often it can be called, try again later")
+	case errors.Is(err, client.ErrUpstreamUnavailable):
+		// Only the log gets the details, which can include the API's address.
+		log.Println("upstream unavailable:", err)
+		if errors.As(err, &status) {
+			setRetryAfter(w, status.RetryAfter)
+		}
+		writeProblem(w, r, problemUpstreamUnavailable, http.StatusServiceUnavailable,
+			"The Council's API is unavailable, try again later")
+	case errors.Is(err, client.ErrInvalidInput):
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, err.Error())
+	case errors.Is(err, client.ErrNotFound):
+		writeProblem(w, r, problemNotFound, http.StatusNotFound, err.Error())
+	default:
+		// Only the log gets the details, which can include the API's address
+		// and whatever it answered with.
+		log.Println("internal error:", err)
+		writeProblem(w, r, problemInternal, http.StatusInternalServerError, "Something went wrong")
+	}
+}
@@ -1,3 +1,54 @@
 package handler_test
 
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
 const RequestUrl = "/"
+
+// Gives every host a public address, except those ending in .internal.
+func fakeDns(ctx context.Context, host string) ([]netip.Addr, error) {
+	if strings.HasSuffix(host, ".internal") {
+		return []netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil
+	}
+	return []netip.Addr{netip.MustParseAddr("93.184.215.14")}, nil
+}
+
+// A client for the canned responses for PropertyId, at noon on 1 January 2024.
+// Shared by the tests of the subscription handlers.
+// Serves the canned responses for PropertyId, counting how often each URL is fetched.
+func propertyApiServer(fetches map[string]int) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches[r.URL.String()] += 1
+		mu.Unlock()
+		if strings.Contains(r.URL.String(), PropertyId) {
+			fmt.Fprintf(w, BinIdJsonResponse)
+		}
+		if strings.Contains(r.URL.String(), BinId1) && strings.Contains(r.URL.String(), "/getbin/") {
+			fmt.Fprintf(w, Bin1TypeJsonResponse)
+		}
+		if strings.Contains(r.URL.String(), BinId2) && strings.Contains(r.URL.String(), "/getbin/") {
+			fmt.Fprintf(w, Bin2TypeJsonResponse)
+		}
+		if strings.Contains(r.URL.String(), WorkflowId1) && strings.Contains(r.URL.String(), "/getworkflow/") {
+			fmt.Fprintf(w, Workflow1ScheduleJsonResponse)
+		}
+		if strings.Contains(r.URL.String(), WorkflowId2) && strings.Contains(r.URL.String(), "/getworkflow/") {
+			fmt.Fprintf(w, Workflow2ScheduleJsonResponse)
+		}
+		if strings.Contains(r.URL.String(), BinId1) && strings.Contains(r.URL.String(), "/getcollection/") {
+			fmt.Fprintf(w, Bin1WorkflowIdJsonResponse)
+		}
+		if strings.Contains(r.URL.String(), BinId2) && strings.Contains(r.URL.String(), "/getcollection/") {
+			fmt.Fprintf(w, Bin2WorkflowIdJsonResponse)
+		}
+	}))
+}
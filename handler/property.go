@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"golang.org/x/sync/errgroup"
+)
+
+type propertyBin struct {
+	Id       string
+	Type     client.BinType
+	Schedule []client.Date
+}
+
+type property struct {
+	Id   string
+	Name string
+	Bins []propertyBin
+}
+
+// Makes all the calls needed to find every bin at a property and its
+// upcoming collection dates. Shared by the handlers that describe a property.
+// Outstanding calls are abandoned as soon as one fails or ctx is done.
+func fetchProperty(ctx context.Context, c client.BinsClient, propertyId string) (property, error) {
+	binIds, err := c.GetBinIdsContext(ctx, propertyId)
+	if err != nil {
+		return property{}, err
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	binTypes := make([]client.BinType, len(binIds.Ids))
+	binSchedules := make([][]client.Date, len(binIds.Ids))
+	// Bins often share a workflow, so fetch each schedule once, the first
+	// time any bin needs it, and give every bin that needs it the same result.
+	var schedules sync.Map
+	schedule := func(workflowId string) ([]client.Date, error) {
+		fetch, _ := schedules.LoadOrStore(workflowId, sync.OnceValues(func() ([]client.Date, error) {
+			return c.GetWorkflowScheduleContext(ctx, workflowId)
+		}))
+		return fetch.(func() ([]client.Date, error))()
+	}
+	for i, binId := range binIds.Ids {
+		i := i
+		binId := binId
+		g.Go(func() error {
+			binType, err := c.GetBinTypeContext(ctx, binId)
+			if err != nil {
+				return err
+			}
+			binTypes[i] = binType
+			return nil
+		})
+		g.Go(func() error {
+			workflowIds, err := c.GetBinWorkflowIdsContext(ctx, binId)
+			if err != nil {
+				return err
+			}
+			var dates []client.Date
+			for _, workflowId := range workflowIds {
+				s, err := schedule(workflowId)
+				if err != nil {
+					return err
+				}
+				dates = append(dates, s...)
+			}
+			binSchedules[i] = mergeSchedule(dates)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return property{}, err
+	}
+
+	var bins []propertyBin
+	for i, binId := range binIds.Ids {
+		bins = append(bins, propertyBin{
+			Id:       binId,
+			Type:     binTypes[i],
+			Schedule: binSchedules[i],
+		})
+	}
+
+	return property{
+		Id:   propertyId,
+		Name: binIds.Name,
+		Bins: bins,
+	}, nil
+}
+
+// Workflows for the same bin can overlap, so sort the combined dates and
+// drop any that appear more than once.
+func mergeSchedule(schedule []client.Date) []client.Date {
+	slices.SortFunc(schedule, client.Date.Compare)
+	return slices.Compact(schedule)
+}
+
+// Which collections are upcoming changes at midnight, so responses listing
+// them must not be cached past then, however long responses are usually kept.
+func untilMidnight(ttl time.Duration, now time.Time) (time.Duration, error) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		return 0, err
+	}
+	now = now.In(london)
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, london)
+	if ttl <= 0 || ttl > midnight.Sub(now) {
+		return midnight.Sub(now), nil
+	}
+	return ttl, nil
+}
+
+// Caches res until midnight at the latest, unless it is stale.
+func cacheProperty(cache client.Cache, ttls client.TTLs, key string, res string, now time.Time, stale bool) {
+	if stale {
+		return
+	}
+	ttl, err := untilMidnight(ttls.Responses, now)
+	if err != nil {
+		return
+	}
+	client.CachedResponses.AddFor(cache, key, res, ttl)
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+)
+
+// Lets people ask for a notification to be pushed to their own ntfy or
+// Gotify server the day before each collection at a property, and stop it
+// again.
+type PushSubscriptionHandler struct {
+	Client client.BinsClient
+	Pushes *reminder.Store[reminder.PushSubscription]
+	// Finds the addresses of servers' hosts. Nil means DNS.
+	LookupIP reminder.LookupIP
+}
+
+func (h *PushSubscriptionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PropertyId string
+		Service    string
+		Url        string
+		Token      string
+		Time       *reminder.TimeOfDay
+		Types      []client.RefuseType
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "Body must be JSON with a PropertyId and a Url: "+err.Error())
+		return
+	}
+	if req.PropertyId == "" {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "PropertyId must not be empty")
+		return
+	}
+	switch req.Service {
+	case "":
+		req.Service = reminder.Ntfy
+	case reminder.Ntfy, reminder.Gotify:
+	default:
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "Service must be ntfy or gotify")
+		return
+	}
+	if req.Service == reminder.Gotify && req.Token == "" {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "Token must not be empty for gotify")
+		return
+	}
+	target, err := subscriberUrl(r.Context(), h.LookupIP, "Url", req.Url, "http", "https")
+	if err != nil {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, err.Error())
+		return
+	}
+	// The same time as the other reminders, unless asked otherwise.
+	at := reminder.TimeOfDay{Hour: 18}
+	if req.Time != nil {
+		at = *req.Time
+	}
+	if _, err := h.Client.GetBinIdsContext(r.Context(), req.PropertyId); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	sub := reminder.PushSubscription{
+		Id:         reminder.NewToken(16),
+		PropertyId: req.PropertyId,
+		Service:    req.Service,
+		Url:        target.String(),
+		Token:      req.Token,
+		Time:       at,
+		Types:      req.Types,
+		Secret:     reminder.NewToken(32),
+		Created:    h.Client.Clock.Now(),
+	}
+	if !putSubscription(w, r, h.Pushes, sub.Id, sub) {
+		return
+	}
+	res, err := json.Marshal(sub)
+	if err != nil {
+		writeProblem(w, r, problemInternal, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/subscriptions/push/"+sub.Id)
+	w.WriteHeader(http.StatusCreated)
+	w.Write(res)
+}
+
+// Needs the subscription's secret as a bearer token.
+func (h *PushSubscriptionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	deleteWithSecret(w, r, h.Pushes, func(sub reminder.PushSubscription) string { return sub.Secret })
+}
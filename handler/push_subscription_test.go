@@ -0,0 +1,158 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/handler"
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+	"github.com/gorilla/mux"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePushSubscription(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.PushSubscription]("")
+	h := handler.PushSubscriptionHandler{Client: binsClient, Pushes: store, LookupIP: fakeDns}
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions/push", strings.NewReader(`
+		{
+			"PropertyId": "property_id",
+			"Service": "gotify",
+			"Url": "https://gotify.example.com/message",
+			"Token": "app-token",
+			"Time": "07:30",
+			"Types": ["recycling", "food"]
+		}`))
+	w := httptest.NewRecorder()
+
+	h.Create(w, r)
+
+	var res reminder.PushSubscription
+	json.Unmarshal(w.Body.Bytes(), &res)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/subscriptions/push/"+res.Id, w.Header().Get("Location"))
+	assert.Equal(t, PropertyId, res.PropertyId)
+	assert.Equal(t, reminder.Gotify, res.Service)
+	assert.Equal(t, "https://gotify.example.com/message", res.Url)
+	assert.Equal(t, "app-token", res.Token)
+	assert.Equal(t, reminder.TimeOfDay{Hour: 7, Minute: 30}, res.Time)
+	assert.Equal(t, []client.RefuseType{client.Recycling, client.Food}, res.Types)
+	assert.Len(t, res.Secret, 64)
+	assert.Equal(t, []reminder.PushSubscription{res}, store.All())
+}
+
+func TestCreatePushSubscriptionDefaults(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.PushSubscription]("")
+	h := handler.PushSubscriptionHandler{Client: binsClient, Pushes: store, LookupIP: fakeDns}
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions/push", strings.NewReader(`
+		{"PropertyId": "property_id", "Url": "https://ntfy.sh/bins"}`))
+	w := httptest.NewRecorder()
+
+	h.Create(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	sub := store.All()[0]
+	assert.Equal(t, reminder.Ntfy, sub.Service)
+	assert.Equal(t, reminder.TimeOfDay{Hour: 18}, sub.Time)
+	assert.Empty(t, sub.Types)
+}
+
+func TestCreatePushSubscriptionBadRequests(t *testing.T) {
+	tests := map[string]string{
+		`not json`: "Body must be JSON",
+		`{"PropertyId": "property_id", "Url": "https://ntfy.sh/bins", "Extra": 1}`:                               "Body must be JSON",
+		`{"PropertyId": "property_id", "Url": "https://ntfy.sh/bins", "Time": "7pm"}`:                            "Not a time of day",
+		`{"PropertyId": "property_id", "Url": "https://ntfy.sh/bins", "Types": ["compost"]}`:                     "Unknown type of refuse",
+		`{"Url": "https://ntfy.sh/bins"}`:                                                                        "PropertyId must not be empty",
+		`{"PropertyId": "property_id", "Url": "https://ntfy.sh/bins", "Service": "pushover"}`:                    "Service must be",
+		`{"PropertyId": "property_id", "Url": "https://gotify.example.com", "Service": "gotify"}`:                "Token must not be empty",
+		`{"PropertyId": "property_id", "Url": "ntfy.sh/bins"}`:                                                   "Url must be",
+		`{"PropertyId": "property_id"}`:                                                                          "Url must be",
+		`{"PropertyId": "property_id", "Url": "http://ntfy.internal:8080/bins"}`:                                 "public internet",
+		`{"PropertyId": "property_id", "Url": "http://192.168.1.10/message", "Service": "gotify", "Token": "t"}`: "public internet",
+	}
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	for body, message := range tests {
+		store, _ := reminder.NewStore[reminder.PushSubscription]("")
+		h := handler.PushSubscriptionHandler{Client: binsClient, Pushes: store, LookupIP: fakeDns}
+		r, _ := http.NewRequest(http.MethodPost, "/subscriptions/push", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		h.Create(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), message, body)
+		assert.Empty(t, store.All())
+	}
+}
+
+func TestCreatePushSubscriptionWhenFull(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.PushSubscription]("")
+	h := handler.PushSubscriptionHandler{Client: binsClient, Pushes: store, LookupIP: fakeDns}
+	store.MaxItems = 1
+	store.Put("other", reminder.PushSubscription{Id: "other"})
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions/push", strings.NewReader(`
+		{"PropertyId": "property_id", "Url": "https://ntfy.sh/bins"}`))
+	w := httptest.NewRecorder()
+
+	h.Create(w, r)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "/problems/too-many-subscriptions")
+	assert.Len(t, store.All(), 1)
+}
+
+func TestDeletePushSubscription(t *testing.T) {
+	store, _ := reminder.NewStore[reminder.PushSubscription]("")
+	h := handler.PushSubscriptionHandler{Pushes: store}
+	store.Put("abc", reminder.PushSubscription{Id: "abc", Secret: "secret"})
+	r, _ := http.NewRequest(http.MethodDelete, "/subscriptions/push/abc", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r = mux.SetURLVars(r, map[string]string{"id": "abc"})
+	w := httptest.NewRecorder()
+
+	h.Delete(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, store.All())
+}
+
+func TestDeletePushSubscriptionNeedsSecret(t *testing.T) {
+	store, _ := reminder.NewStore[reminder.PushSubscription]("")
+	h := handler.PushSubscriptionHandler{Pushes: store}
+	store.Put("abc", reminder.PushSubscription{Id: "abc", Secret: "secret"})
+	r, _ := http.NewRequest(http.MethodDelete, "/subscriptions/push/abc", nil)
+	r.Header.Set("Authorization", "Bearer wrong")
+	r = mux.SetURLVars(r, map[string]string{"id": "abc"})
+	w := httptest.NewRecorder()
+
+	h.Delete(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Len(t, store.All(), 1)
+}
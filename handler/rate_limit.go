@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/jonboulle/clockwork"
+)
+
+// Limits how often each client can call an endpoint, so that nobody can
+// create subscriptions faster than people could want them. Each client has
+// a bucket of Burst calls, which refills at one call every Every. Safe for
+// concurrent use, but must not be copied after first use.
+type RateLimiter struct {
+	Burst int
+	Every time.Duration
+	// Nil means the real clock.
+	Clock clockwork.Clock
+	// The header a proxy in front of this service puts the client's address
+	// in, such as Fly-Client-IP. Empty means use the connection's address.
+	ClientIpHeader string
+
+	mu sync.Mutex
+	// Only the most recent clients are remembered, so that memory is bounded.
+	buckets *lru.Cache[string, bucket]
+}
+
+type bucket struct {
+	calls   float64
+	updated time.Time
+}
+
+func (l *RateLimiter) clientIp(r *http.Request) string {
+	if l.ClientIpHeader != "" {
+		if ip := r.Header.Get(l.ClientIpHeader); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Uses up one of the client's calls, if they have one left. If not, says how
+// long until they will.
+func (l *RateLimiter) allow(client string) (time.Duration, bool) {
+	now := time.Now()
+	if l.Clock != nil {
+		now = l.Clock.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets, _ = lru.New[string, bucket](4096)
+	}
+	b, found := l.buckets.Get(client)
+	if !found {
+		b = bucket{calls: float64(l.Burst), updated: now}
+	}
+	b.calls = min(float64(l.Burst), b.calls+float64(now.Sub(b.updated))/float64(l.Every))
+	b.updated = now
+	if b.calls < 1 {
+		l.buckets.Add(client, b)
+		return time.Duration((1 - b.calls) * float64(l.Every)), false
+	}
+	b.calls--
+	l.buckets.Add(client, b)
+	return 0, true
+}
+
+// Wraps next so that clients calling it too often get a 429 error instead.
+func (l *RateLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if wait, ok := l.allow(l.clientIp(r)); !ok {
+			setRetryAfter(w, wait)
+			writeProblem(w, r, problemRateLimited, http.StatusTooManyRequests, "Too many requests, try again later")
+			return
+		}
+		next(w, r)
+	}
+}
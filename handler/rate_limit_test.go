@@ -0,0 +1,68 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/handler"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func limited(l *handler.RateLimiter, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+	h := l.Limit(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions", nil)
+	r.RemoteAddr = remoteAddr
+	if header != nil {
+		r.Header = header
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func TestRateLimitAllowsBurst(t *testing.T) {
+	l := &handler.RateLimiter{Burst: 2, Every: time.Minute, Clock: clockwork.NewFakeClock()}
+
+	assert.Equal(t, http.StatusCreated, limited(l, "1.2.3.4:1000", nil).Code)
+	assert.Equal(t, http.StatusCreated, limited(l, "1.2.3.4:1001", nil).Code)
+	w := limited(l, "1.2.3.4:1002", nil)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "/problems/rate-limited")
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+}
+
+func TestRateLimitRefills(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	l := &handler.RateLimiter{Burst: 1, Every: time.Minute, Clock: clock}
+	limited(l, "1.2.3.4:1000", nil)
+
+	clock.Advance(time.Second * 45)
+	w := limited(l, "1.2.3.4:1000", nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "15", w.Header().Get("Retry-After"))
+
+	clock.Advance(time.Second * 15)
+	assert.Equal(t, http.StatusCreated, limited(l, "1.2.3.4:1000", nil).Code)
+}
+
+func TestRateLimitPerClient(t *testing.T) {
+	l := &handler.RateLimiter{Burst: 1, Every: time.Minute, Clock: clockwork.NewFakeClock()}
+	limited(l, "1.2.3.4:1000", nil)
+
+	assert.Equal(t, http.StatusCreated, limited(l, "5.6.7.8:1000", nil).Code)
+}
+
+func TestRateLimitByHeader(t *testing.T) {
+	l := &handler.RateLimiter{Burst: 1, Every: time.Minute, Clock: clockwork.NewFakeClock(), ClientIpHeader: "Fly-Client-IP"}
+	limited(l, "10.0.0.1:1000", http.Header{"Fly-Client-Ip": {"1.2.3.4"}})
+
+	assert.Equal(t, http.StatusTooManyRequests, limited(l, "10.0.0.1:1000", http.Header{"Fly-Client-Ip": {"1.2.3.4"}}).Code)
+	assert.Equal(t, http.StatusCreated, limited(l, "10.0.0.1:1000", http.Header{"Fly-Client-Ip": {"5.6.7.8"}}).Code)
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/gorilla/mux"
+)
+
+type ScheduleHandler struct {
+	Client    client.BinsClient
+	Cache     client.Cache
+	CacheTTLs client.TTLs
+	// Give dates as timestamps at midnight in London, as they used to be.
+	LegacyDates bool
+}
+
+type scheduleQuery struct {
+	From  client.Date
+	To    client.Date
+	Limit int
+}
+
+func parseScheduleQuery(r *http.Request) (scheduleQuery, error) {
+	var q scheduleQuery
+	var err error
+	params := r.URL.Query()
+	if from := params.Get("from"); from != "" {
+		q.From, err = client.ParseDate(from)
+		if err != nil {
+			return q, fmt.Errorf("from must be a date in the format YYYY-MM-DD")
+		}
+	}
+	if to := params.Get("to"); to != "" {
+		q.To, err = client.ParseDate(to)
+		if err != nil {
+			return q, fmt.Errorf("to must be a date in the format YYYY-MM-DD")
+		}
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return q, fmt.Errorf("to must not be before from")
+	}
+	if limit := params.Get("limit"); limit != "" {
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit < 1 {
+			return q, fmt.Errorf("limit must be a positive whole number")
+		}
+	}
+	return q, nil
+}
+
+// Both ends of the range are inclusive.
+func (q scheduleQuery) filter(schedule []client.Date) []client.Date {
+	res := []client.Date{}
+	for _, date := range schedule {
+		if !q.From.IsZero() && date.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && date.After(q.To) {
+			continue
+		}
+		res = append(res, date)
+		if q.Limit > 0 && len(res) == q.Limit {
+			break
+		}
+	}
+	return res
+}
+
+func (h *ScheduleHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	propertyId := vars["property_id"]
+	if propertyId == "" {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "URL did not include property_id")
+		return
+	}
+	query, err := parseScheduleQuery(r)
+	if err != nil {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if res, found := client.CachedResponses.Get(h.Cache, r.URL.String()); found {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, res)
+		return
+	}
+
+	ctx, staleness := client.TrackStaleness(r.Context())
+	p, err := fetchProperty(ctx, h.Client, propertyId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	type bin struct {
+		Name        string
+		Type        string
+		Collections []responseDate
+	}
+	type result struct {
+		PropertyId string
+		Name       string
+		Bins       []bin
+		Stale      bool `json:",omitempty"`
+	}
+	bins := []bin{}
+	for _, b := range p.Bins {
+		collections := []responseDate{}
+		for _, date := range query.filter(b.Schedule) {
+			collections = append(collections, responseDate{date, h.LegacyDates})
+		}
+		if len(collections) == 0 {
+			continue
+		}
+		bins = append(bins, bin{
+			Name:        b.Type.Name,
+			Type:        b.Type.Type.String(),
+			Collections: collections,
+		})
+	}
+
+	stale := markStale(w, staleness)
+	resBytes, err := json.Marshal(result{
+		PropertyId: p.Id,
+		Name:       p.Name,
+		Bins:       bins,
+		Stale:      stale,
+	})
+	if err != nil {
+		writeProblem(w, r, problemInternal, http.StatusInternalServerError, err.Error())
+		return
+	}
+	res := string(resBytes)
+	cacheProperty(h.Cache, h.CacheTTLs, r.URL.String(), res, h.Client.Clock.Now(), stale)
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, res)
+}
@@ -0,0 +1,226 @@
+package handler_test
+
+import (
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/handler"
+
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	_ "time/tzdata"
+
+	"github.com/gorilla/mux"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func getSchedule(t *testing.T, query string) *httptest.ResponseRecorder {
+	return getScheduleWith(t, query, false)
+}
+
+func getScheduleWith(t *testing.T, query string, legacyDates bool) *httptest.ResponseRecorder {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl+query, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r = mux.SetURLVars(r, vars)
+	london, _ := time.LoadLocation("Europe/London")
+	now := time.Date(2023, 12, 15, 3, 19, 46, 72, london)
+	clock := clockwork.NewFakeClockAt(now)
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.ScheduleHandler{Client: client, LegacyDates: legacyDates}
+
+	handler.Handle(w, r)
+	return w
+}
+
+func TestScheduleNoPropertyId(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": "",
+	}
+	r = mux.SetURLVars(r, vars)
+	clock := clockwork.NewFakeClock()
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: &url.URL{}}
+	handler := handler.ScheduleHandler{Client: client}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "include property_id")
+}
+
+func TestScheduleBadQuery(t *testing.T) {
+	for query, message := range map[string]string{
+		"?from=tomorrow":                 "from must be a date",
+		"?to=2024-13-01":                 "to must be a date",
+		"?from=2024-01-02&to=2024-01-01": "to must not be before from",
+		"?limit=0":                       "limit must be a positive",
+		"?limit=lots":                    "limit must be a positive",
+	} {
+		w := getSchedule(t, query)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Contains(t, w.Body.String(), message, query)
+	}
+}
+
+func TestScheduleEveryUpcomingCollection(t *testing.T) {
+	w := getSchedule(t, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `
+		{
+			"PropertyId": "property_id",
+			"Name": "29 ACACIA AVENUE",
+			"Bins": [
+				{
+					"Name": "Garbage can",
+					"Type": "garden",
+					"Collections": ["2024-01-01", "2025-07-01"]
+				},
+				{
+					"Name": "Dumpster",
+					"Type": "unknown",
+					"Collections": ["2024-01-02", "2025-07-02"]
+				}
+			]
+		}`, w.Body.String())
+}
+
+func TestScheduleFrom(t *testing.T) {
+	w := getSchedule(t, "?from=2024-01-02")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `
+		{
+			"PropertyId": "property_id",
+			"Name": "29 ACACIA AVENUE",
+			"Bins": [
+				{
+					"Name": "Garbage can",
+					"Type": "garden",
+					"Collections": ["2025-07-01"]
+				},
+				{
+					"Name": "Dumpster",
+					"Type": "unknown",
+					"Collections": ["2024-01-02", "2025-07-02"]
+				}
+			]
+		}`, w.Body.String())
+}
+
+func TestScheduleToSkipsBinsWithNoCollections(t *testing.T) {
+	w := getSchedule(t, "?to=2024-01-01")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `
+		{
+			"PropertyId": "property_id",
+			"Name": "29 ACACIA AVENUE",
+			"Bins": [
+				{
+					"Name": "Garbage can",
+					"Type": "garden",
+					"Collections": ["2024-01-01"]
+				}
+			]
+		}`, w.Body.String())
+}
+
+func TestScheduleNothingInRange(t *testing.T) {
+	w := getSchedule(t, "?from=2030-01-01")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `
+		{
+			"PropertyId": "property_id",
+			"Name": "29 ACACIA AVENUE",
+			"Bins": []
+		}`, w.Body.String())
+}
+
+func TestScheduleLimit(t *testing.T) {
+	w := getSchedule(t, "?from=2024-01-01&to=2025-12-31&limit=1")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `
+		{
+			"PropertyId": "property_id",
+			"Name": "29 ACACIA AVENUE",
+			"Bins": [
+				{
+					"Name": "Garbage can",
+					"Type": "garden",
+					"Collections": ["2024-01-01"]
+				},
+				{
+					"Name": "Dumpster",
+					"Type": "unknown",
+					"Collections": ["2024-01-02"]
+				}
+			]
+		}`, w.Body.String())
+}
+
+func TestScheduleFetchOnceWithCache(t *testing.T) {
+	fetches := make(map[string]int)
+	apiSvr := propertyApiServer(fetches)
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	r1, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w1 := httptest.NewRecorder()
+	r2, _ := http.NewRequest(http.MethodGet, RequestUrl, nil)
+	w2 := httptest.NewRecorder()
+	vars := map[string]string{
+		"property_id": PropertyId,
+	}
+	r1 = mux.SetURLVars(r1, vars)
+	r2 = mux.SetURLVars(r2, vars)
+	clock := clockwork.NewFakeClock()
+	cache, _ := client.NewMemoryCache(1024, clock)
+	client := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	handler := handler.ScheduleHandler{Client: client, Cache: cache}
+
+	handler.Handle(w1, r1)
+	handler.Handle(w2, r2)
+
+	assert.Equal(t, http.StatusOK, w2.Code)
+	assert.Equal(t, w1.Body.String(), w2.Body.String())
+	for _, v := range fetches {
+		assert.Equal(t, 1, v)
+	}
+}
+
+func TestScheduleLegacyDates(t *testing.T) {
+	w := getScheduleWith(t, "?from=2024-01-02", true)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `
+		{
+			"PropertyId": "property_id",
+			"Name": "29 ACACIA AVENUE",
+			"Bins": [
+				{
+					"Name": "Garbage can",
+					"Type": "garden",
+					"Collections": ["2025-07-01T00:00:00+01:00"]
+				},
+				{
+					"Name": "Dumpster",
+					"Type": "unknown",
+					"Collections": ["2024-01-02T00:00:00Z", "2025-07-02T00:00:00+01:00"]
+				}
+			]
+		}`, w.Body.String())
+}
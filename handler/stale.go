@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+)
+
+// Warns the caller if the client fell back on old data because the Council's
+// API failed. Returns whether it did, in which case the response should not
+// be cached.
+func markStale(w http.ResponseWriter, s *client.Staleness) bool {
+	age, stale := s.Age()
+	if !stale {
+		return false
+	}
+	w.Header().Set("Warning", `110 - "Response is Stale"`)
+	w.Header().Set("X-Data-Age", strconv.Itoa(int(age.Seconds())))
+	return true
+}
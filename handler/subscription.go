@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+	"github.com/gorilla/mux"
+)
+
+// Lets people ask for a webhook to be called the evening before each
+// collection at a property, and stop it again.
+type SubscriptionHandler struct {
+	Client   client.BinsClient
+	Webhooks *reminder.Store[reminder.Webhook]
+	// Finds the addresses of webhooks' hosts. Nil means DNS.
+	LookupIP reminder.LookupIP
+}
+
+// Checks the URL a subscription sends to uses one of schemes, and is on the
+// public internet.
+func subscriberUrl(ctx context.Context, lookup reminder.LookupIP, field, raw string, schemes ...string) (*url.URL, error) {
+	target, err := url.Parse(raw)
+	if err != nil || !slices.Contains(schemes, target.Scheme) || target.Hostname() == "" {
+		return nil, fmt.Errorf("%v must be an absolute %v URL", field, strings.Join(schemes, " or "))
+	}
+	if err := reminder.CheckPublicHost(ctx, lookup, target.Hostname()); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// Saves a new subscription, or sends an error response and reports that it
+// couldn't.
+func putSubscription[T any](w http.ResponseWriter, r *http.Request, store *reminder.Store[T], id string, item T) bool {
+	err := store.Put(id, item)
+	if errors.Is(err, reminder.ErrFull) {
+		writeProblem(w, r, problemTooManySubscriptions, http.StatusConflict, err.Error())
+		return false
+	} else if err != nil {
+		writeProblem(w, r, problemInternal, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return true
+}
+
+// Deletes the subscription with the ID in the path, if the request has its
+// secret as a bearer token.
+func deleteWithSecret[T any](w http.ResponseWriter, r *http.Request, store *reminder.Store[T], secretOf func(T) string) {
+	id := mux.Vars(r)["id"]
+	item, found := store.Get(id)
+	if !found {
+		writeProblem(w, r, problemNotFound, http.StatusNotFound, "No subscription with that ID")
+		return
+	}
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(secret), []byte(secretOf(item))) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeProblem(w, r, problemUnauthorized, http.StatusUnauthorized, "The subscription's secret is needed to delete it")
+		return
+	}
+	if err := store.Delete(id); err != nil {
+		writeProblem(w, r, problemInternal, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *SubscriptionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PropertyId string
+		Url        string
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "Body must be JSON with a PropertyId and a Url")
+		return
+	}
+	if req.PropertyId == "" {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "PropertyId must not be empty")
+		return
+	}
+	target, err := subscriberUrl(r.Context(), h.LookupIP, "Url", req.Url, "http", "https")
+	if err != nil {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Check the property exists, so mistakes show up now rather than as
+	// reminders that never come.
+	if _, err := h.Client.GetBinIdsContext(r.Context(), req.PropertyId); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	hook := reminder.Webhook{
+		Id:         reminder.NewToken(16),
+		PropertyId: req.PropertyId,
+		Url:        target.String(),
+		Secret:     reminder.NewToken(32),
+		Created:    h.Client.Clock.Now(),
+	}
+	if !putSubscription(w, r, h.Webhooks, hook.Id, hook) {
+		return
+	}
+	res, err := json.Marshal(hook)
+	if err != nil {
+		writeProblem(w, r, problemInternal, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/subscriptions/"+hook.Id)
+	w.WriteHeader(http.StatusCreated)
+	w.Write(res)
+}
+
+// Needs the subscription's secret as a bearer token.
+func (h *SubscriptionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	deleteWithSecret(w, r, h.Webhooks, func(hook reminder.Webhook) string { return hook.Secret })
+}
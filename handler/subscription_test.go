@@ -0,0 +1,160 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/handler"
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+	"github.com/gorilla/mux"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateSubscription(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.Webhook]("")
+	h := handler.SubscriptionHandler{Client: binsClient, Webhooks: store, LookupIP: fakeDns}
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(`
+		{"PropertyId": "property_id", "Url": "https://example.com/bins"}`))
+	w := httptest.NewRecorder()
+
+	h.Create(w, r)
+
+	var res reminder.Webhook
+	json.Unmarshal(w.Body.Bytes(), &res)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/subscriptions/"+res.Id, w.Header().Get("Location"))
+	assert.Equal(t, PropertyId, res.PropertyId)
+	assert.Equal(t, "https://example.com/bins", res.Url)
+	assert.Len(t, res.Secret, 64)
+	assert.Equal(t, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), res.Created)
+	assert.Equal(t, []reminder.Webhook{res}, store.All())
+}
+
+func TestCreateSubscriptionBadRequests(t *testing.T) {
+	tests := map[string]string{
+		`not json`: "Body must be JSON",
+		`{"PropertyId": "property_id", "Url": "https://example.com", "Extra": 1}`: "Body must be JSON",
+		`{"Url": "https://example.com"}`:                                          "PropertyId must not be empty",
+		`{"PropertyId": "property_id", "Url": "example.com/bins"}`:                "Url must be",
+		`{"PropertyId": "property_id", "Url": "ftp://example.com/bins"}`:          "Url must be",
+		`{"PropertyId": "property_id"}`:                                           "Url must be",
+		`{"PropertyId": "property_id", "Url": "http://127.0.0.1:8080/bins"}`:      "public internet",
+		`{"PropertyId": "property_id", "Url": "http://[::1]/bins"}`:               "public internet",
+		`{"PropertyId": "property_id", "Url": "http://169.254.169.254/latest"}`:   "public internet",
+		`{"PropertyId": "property_id", "Url": "https://bins.internal/hook"}`:      "public internet",
+	}
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	for body, message := range tests {
+		store, _ := reminder.NewStore[reminder.Webhook]("")
+		h := handler.SubscriptionHandler{Client: binsClient, Webhooks: store, LookupIP: fakeDns}
+		r, _ := http.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		h.Create(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), message, body)
+		assert.Empty(t, store.All())
+	}
+}
+
+func TestCreateSubscriptionWhenFull(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.Webhook]("")
+	h := handler.SubscriptionHandler{Client: binsClient, Webhooks: store, LookupIP: fakeDns}
+	store.MaxItems = 1
+	store.Put("other", reminder.Webhook{Id: "other"})
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(`
+		{"PropertyId": "property_id", "Url": "https://example.com/bins"}`))
+	w := httptest.NewRecorder()
+
+	h.Create(w, r)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "/problems/too-many-subscriptions")
+	assert.Len(t, store.All(), 1)
+}
+
+func TestCreateSubscriptionForBadProperty(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadRequest)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	store, _ := reminder.NewStore[reminder.Webhook]("")
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clockwork.NewFakeClock(), ApiHost: apiUrl}
+	h := handler.SubscriptionHandler{Client: binsClient, Webhooks: store, LookupIP: fakeDns}
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(`
+		{"PropertyId": "nonsense", "Url": "https://example.com/bins"}`))
+	w := httptest.NewRecorder()
+
+	h.Create(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, store.All())
+}
+
+func TestDeleteSubscription(t *testing.T) {
+	store, _ := reminder.NewStore[reminder.Webhook]("")
+	h := handler.SubscriptionHandler{Webhooks: store}
+	store.Put("abc", reminder.Webhook{Id: "abc", Secret: "secret"})
+	r, _ := http.NewRequest(http.MethodDelete, "/subscriptions/abc", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r = mux.SetURLVars(r, map[string]string{"id": "abc"})
+	w := httptest.NewRecorder()
+
+	h.Delete(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, store.All())
+}
+
+func TestDeleteSubscriptionNeedsSecret(t *testing.T) {
+	for _, auth := range []string{"", "Bearer wrong", "secret"} {
+		store, _ := reminder.NewStore[reminder.Webhook]("")
+		h := handler.SubscriptionHandler{Webhooks: store}
+		store.Put("abc", reminder.Webhook{Id: "abc", Secret: "secret"})
+		r, _ := http.NewRequest(http.MethodDelete, "/subscriptions/abc", nil)
+		r.Header.Set("Authorization", auth)
+		r = mux.SetURLVars(r, map[string]string{"id": "abc"})
+		w := httptest.NewRecorder()
+
+		h.Delete(w, r)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, auth)
+		assert.Len(t, store.All(), 1)
+	}
+}
+
+func TestDeleteMissingSubscription(t *testing.T) {
+	store, _ := reminder.NewStore[reminder.Webhook]("")
+	h := handler.SubscriptionHandler{Webhooks: store}
+	r, _ := http.NewRequest(http.MethodDelete, "/subscriptions/abc", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r = mux.SetURLVars(r, map[string]string{"id": "abc"})
+	w := httptest.NewRecorder()
+
+	h.Delete(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
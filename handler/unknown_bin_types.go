@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+)
+
+// Lists the types of container that no classification rule matched, for
+// whoever maintains the rules. Only answers requests that give Token as a
+// bearer token.
+type UnknownBinTypesHandler struct {
+	UnknownBinTypes *client.UnknownBinTypes
+	Token           string
+}
+
+func (h *UnknownBinTypesHandler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && h.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) == 1
+}
+
+func (h *UnknownBinTypesHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeProblem(w, r, problemUnauthorized, http.StatusUnauthorized, "A valid admin token is needed")
+		return
+	}
+
+	unknown := []client.UnknownBinType{}
+	if h.UnknownBinTypes != nil {
+		unknown = h.UnknownBinTypes.List()
+	}
+	res, err := json.Marshal(unknown)
+	if err != nil {
+		writeProblem(w, r, problemInternal, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(res)
+}
@@ -0,0 +1,91 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/handler"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+const AdminToken = "secret"
+
+func TestUnknownBinTypesNeedsToken(t *testing.T) {
+	for _, auth := range []string{"", "Bearer wrong", "secret", "Basic secret"} {
+		r, _ := http.NewRequest(http.MethodGet, "/admin/unknown-bin-types", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		unknown, _ := client.NewUnknownBinTypes(10, clockwork.NewFakeClock())
+		handler := handler.UnknownBinTypesHandler{UnknownBinTypes: unknown, Token: AdminToken}
+
+		handler.Handle(w, r)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, auth)
+		assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestUnknownBinTypesRefusedWithoutConfiguredToken(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/admin/unknown-bin-types", nil)
+	r.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	handler := handler.UnknownBinTypesHandler{}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestListUnknownBinTypes(t *testing.T) {
+	apiSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"subTitle": "Dumpster", "binType": "abc"}`)
+	}))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	unknown, _ := client.NewUnknownBinTypes(10, clock)
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl, UnknownBinTypes: unknown}
+	binsClient.GetBinType(BinId1)
+	r, _ := http.NewRequest(http.MethodGet, "/admin/unknown-bin-types", nil)
+	r.Header.Set("Authorization", "Bearer "+AdminToken)
+	w := httptest.NewRecorder()
+	handler := handler.UnknownBinTypesHandler{UnknownBinTypes: unknown, Token: AdminToken}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `
+		[
+			{
+				"TypeId": "abc",
+				"SubTitle": "Dumpster",
+				"FirstSeen": "2024-01-01T12:00:00Z",
+				"LastSeen": "2024-01-01T12:00:00Z",
+				"Count": 1,
+				"ExampleBinId": "bin1"
+			}
+		]`, w.Body.String())
+}
+
+func TestListNoUnknownBinTypes(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/admin/unknown-bin-types", nil)
+	r.Header.Set("Authorization", "Bearer "+AdminToken)
+	w := httptest.NewRecorder()
+	unknown, _ := client.NewUnknownBinTypes(10, clockwork.NewFakeClock())
+	handler := handler.UnknownBinTypesHandler{UnknownBinTypes: unknown, Token: AdminToken}
+
+	handler.Handle(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+}
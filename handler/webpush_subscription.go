@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+)
+
+// Lets the web page ask for a notification in the browser the evening before
+// each collection at a property, and stop it again.
+type WebPushSubscriptionHandler struct {
+	Client        client.BinsClient
+	Subscriptions *reminder.Store[reminder.WebPushSubscription]
+	VAPID         reminder.VAPID
+	// Finds the addresses of push services' hosts. Nil means DNS.
+	LookupIP reminder.LookupIP
+}
+
+// The key browsers need to subscribe.
+func (h *WebPushSubscriptionHandler) Key(w http.ResponseWriter, r *http.Request) {
+	res, err := json.Marshal(struct{ PublicKey string }{h.VAPID.PublicKey()})
+	if err != nil {
+		writeProblem(w, r, problemInternal, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+// What a browser's PushSubscription looks like as JSON.
+type browserSubscription struct {
+	Endpoint       string `json:"endpoint"`
+	ExpirationTime *int64 `json:"expirationTime"`
+	Keys           struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// The body is the property ID and the browser's PushSubscription. Subscribing
+// the same browser to the same property again replaces the old subscription.
+func (h *WebPushSubscriptionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PropertyId   string
+		Subscription browserSubscription
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "Body must be JSON with a PropertyId and a Subscription")
+		return
+	}
+	if req.PropertyId == "" {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "PropertyId must not be empty")
+		return
+	}
+	// Push services are always HTTPS.
+	if _, err := subscriberUrl(r.Context(), h.LookupIP, "endpoint", req.Subscription.Endpoint, "https"); err != nil {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, err.Error())
+		return
+	}
+	sub := reminder.WebPushSubscription{
+		Id:         reminder.WebPushSubscriptionId(req.Subscription.Endpoint, req.PropertyId),
+		PropertyId: req.PropertyId,
+		Endpoint:   req.Subscription.Endpoint,
+		P256dh:     req.Subscription.Keys.P256dh,
+		Auth:       req.Subscription.Keys.Auth,
+		Created:    h.Client.Clock.Now(),
+	}
+	if err := sub.CheckKeys(); err != nil {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := h.Client.GetBinIdsContext(r.Context(), req.PropertyId); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !putSubscription(w, r, h.Subscriptions, sub.Id, sub) {
+		return
+	}
+	res, err := json.Marshal(struct{ Id string }{sub.Id})
+	if err != nil {
+		writeProblem(w, r, problemInternal, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(res)
+}
+
+// The body is the property ID and the browser's endpoint, which only the
+// browser knows. It is not an error if there was no such subscription.
+func (h *WebPushSubscriptionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PropertyId string
+		Endpoint   string
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil || req.PropertyId == "" || req.Endpoint == "" {
+		writeProblem(w, r, problemInvalidInput, http.StatusBadRequest, "Body must be JSON with a PropertyId and an Endpoint")
+		return
+	}
+	if err := h.Subscriptions.Delete(reminder.WebPushSubscriptionId(req.Endpoint, req.PropertyId)); err != nil {
+		writeProblem(w, r, problemInternal, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,194 @@
+package handler_test
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/handler"
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+const Endpoint = "https://push.example.com/send/abc"
+
+// A body like the web page sends, with keys like a browser's.
+func webPushBody(endpoint string) string {
+	private, _ := ecdh.P256().GenerateKey(rand.Reader)
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return fmt.Sprintf(`
+		{
+			"PropertyId": "property_id",
+			"Subscription": {
+				"endpoint": %q,
+				"expirationTime": null,
+				"keys": {"p256dh": %q, "auth": %q}
+			}
+		}`, endpoint,
+		base64.RawURLEncoding.EncodeToString(private.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(auth))
+}
+
+func TestWebPushKey(t *testing.T) {
+	// The application server's key from the example in RFC 8291.
+	key, _ := reminder.ParseVAPIDKey("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw")
+	h := handler.WebPushSubscriptionHandler{
+		VAPID: reminder.VAPID{Key: key, Subject: "mailto:bins@example.com"},
+	}
+	r, _ := http.NewRequest(http.MethodGet, "/subscriptions/webpush/key", nil)
+	w := httptest.NewRecorder()
+
+	h.Key(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"PublicKey": "BP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A8"}`, w.Body.String())
+}
+
+func TestCreateWebPushSubscription(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.WebPushSubscription]("")
+	key, _ := reminder.ParseVAPIDKey("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw")
+	h := handler.WebPushSubscriptionHandler{
+		Client:        binsClient,
+		Subscriptions: store,
+		VAPID:         reminder.VAPID{Key: key, Subject: "mailto:bins@example.com"},
+		LookupIP:      fakeDns,
+	}
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions/webpush", strings.NewReader(webPushBody(Endpoint)))
+	w := httptest.NewRecorder()
+
+	h.Create(w, r)
+
+	id := reminder.WebPushSubscriptionId(Endpoint, PropertyId)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"Id": "`+id+`"}`, w.Body.String())
+	subs := store.All()
+	assert.Len(t, subs, 1)
+	assert.Equal(t, id, subs[0].Id)
+	assert.Equal(t, PropertyId, subs[0].PropertyId)
+	assert.Equal(t, Endpoint, subs[0].Endpoint)
+	assert.Nil(t, subs[0].CheckKeys())
+}
+
+func TestSubscribingWebPushTwiceReplaces(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.WebPushSubscription]("")
+	key, _ := reminder.ParseVAPIDKey("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw")
+	h := handler.WebPushSubscriptionHandler{
+		Client:        binsClient,
+		Subscriptions: store,
+		VAPID:         reminder.VAPID{Key: key, Subject: "mailto:bins@example.com"},
+		LookupIP:      fakeDns,
+	}
+	for range 2 {
+		r, _ := http.NewRequest(http.MethodPost, "/subscriptions/webpush", strings.NewReader(webPushBody(Endpoint)))
+		h.Create(httptest.NewRecorder(), r)
+	}
+
+	assert.Len(t, store.All(), 1)
+}
+
+func TestCreateWebPushSubscriptionBadRequests(t *testing.T) {
+	tests := map[string]string{
+		`not json`: "Body must be JSON",
+		`{"PropertyId": "property_id", "Extra": 1}`:                  "Body must be JSON",
+		strings.Replace(webPushBody(Endpoint), "property_id", "", 1): "PropertyId must not be empty",
+		webPushBody("http://push.example.com/send/abc"):              "endpoint must be an absolute https URL",
+		webPushBody("/send/abc"):                                     "endpoint must be an absolute https URL",
+		webPushBody("https://169.254.169.254/latest"):                "public internet",
+		webPushBody("https://push.internal/send/abc"):                "public internet",
+		`{"PropertyId": "property_id", "Subscription": {"endpoint": "` + Endpoint + `", "keys": {"p256dh": "!", "auth": "BTBZMqHH6r4Tts7J_aSIgg"}}}`:    "p256dh must be base64url",
+		`{"PropertyId": "property_id", "Subscription": {"endpoint": "` + Endpoint + `", "keys": {"p256dh": "AAAA", "auth": "BTBZMqHH6r4Tts7J_aSIgg"}}}`: "p256dh must be a P-256 public key",
+	}
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	for body, message := range tests {
+		store, _ := reminder.NewStore[reminder.WebPushSubscription]("")
+		key, _ := reminder.ParseVAPIDKey("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw")
+		h := handler.WebPushSubscriptionHandler{
+			Client:        binsClient,
+			Subscriptions: store,
+			VAPID:         reminder.VAPID{Key: key, Subject: "mailto:bins@example.com"},
+			LookupIP:      fakeDns,
+		}
+		r, _ := http.NewRequest(http.MethodPost, "/subscriptions/webpush", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		h.Create(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), message, body)
+		assert.Empty(t, store.All())
+	}
+}
+
+func TestDeleteWebPushSubscription(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.WebPushSubscription]("")
+	key, _ := reminder.ParseVAPIDKey("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw")
+	h := handler.WebPushSubscriptionHandler{
+		Client:        binsClient,
+		Subscriptions: store,
+		VAPID:         reminder.VAPID{Key: key, Subject: "mailto:bins@example.com"},
+		LookupIP:      fakeDns,
+	}
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions/webpush", strings.NewReader(webPushBody(Endpoint)))
+	h.Create(httptest.NewRecorder(), r)
+	r, _ = http.NewRequest(http.MethodDelete, "/subscriptions/webpush", strings.NewReader(`
+		{"PropertyId": "property_id", "Endpoint": "`+Endpoint+`"}`))
+	w := httptest.NewRecorder()
+
+	h.Delete(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, store.All())
+}
+
+func TestDeleteWebPushSubscriptionNeedsEndpoint(t *testing.T) {
+	apiSvr := propertyApiServer(make(map[string]int))
+	defer apiSvr.Close()
+	apiUrl, _ := url.Parse(apiSvr.URL)
+	clock := clockwork.NewFakeClock()
+	binsClient := client.BinsClient{HttpClient: http.Client{}, Clock: clock, ApiHost: apiUrl}
+	store, _ := reminder.NewStore[reminder.WebPushSubscription]("")
+	key, _ := reminder.ParseVAPIDKey("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw")
+	h := handler.WebPushSubscriptionHandler{
+		Client:        binsClient,
+		Subscriptions: store,
+		VAPID:         reminder.VAPID{Key: key, Subject: "mailto:bins@example.com"},
+		LookupIP:      fakeDns,
+	}
+	r, _ := http.NewRequest(http.MethodPost, "/subscriptions/webpush", strings.NewReader(webPushBody(Endpoint)))
+	h.Create(httptest.NewRecorder(), r)
+	r, _ = http.NewRequest(http.MethodDelete, "/subscriptions/webpush", strings.NewReader(`{"PropertyId": "property_id"}`))
+	w := httptest.NewRecorder()
+
+	h.Delete(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Len(t, store.All(), 1)
+}
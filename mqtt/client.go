@@ -0,0 +1,251 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Packet types from MQTT 3.1.1, which every broker supports.
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetDisconnect = 14
+)
+
+// Something to publish.
+type Message struct {
+	Topic   string
+	Payload []byte
+	// Kept by the broker and sent to anyone who subscribes later.
+	Retain bool
+}
+
+// Publishes to an MQTT broker, connecting afresh each time so that there is
+// no connection to keep alive between times. Messages are sent at QoS 1, so
+// Publish only returns once the broker has them all.
+type Client struct {
+	addr     string
+	useTLS   bool
+	username string
+	password string
+
+	// Identifies this client to the broker, which disconnects any other
+	// client with the same ID. Defaults to "hackney-bindicator-" and four
+	// random hex digits, so that instances don't disconnect each other, and
+	// it stays within the 23 characters every broker accepts.
+	ClientId string
+	// Upper limit on each Publish. Zero means no limit.
+	Timeout time.Duration
+}
+
+// Connects to the broker at rawURL, which looks like
+// mqtt://[user:password@]host:port, or mqtts:// to use TLS.
+func NewClient(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "mqtt" && u.Scheme != "mqtts" {
+		return nil, fmt.Errorf("MQTT URL must start with mqtt:// or mqtts://")
+	}
+	c := &Client{
+		addr:     u.Host,
+		useTLS:   u.Scheme == "mqtts",
+		ClientId: "hackney-bindicator-" + randomHex(2),
+	}
+	if u.Port() == "" {
+		port := "1883"
+		if c.useTLS {
+			port = "8883"
+		}
+		c.addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	// MQTT 3.1.1 only allows a password alongside a user name.
+	if c.password != "" && c.username == "" {
+		return nil, fmt.Errorf("MQTT URL must have a user name to go with the password")
+	}
+	return c, nil
+}
+
+func randomHex(bytes int) string {
+	b := make([]byte, bytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// The reasons a broker can give for refusing to connect.
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client ID rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+func (c *Client) Publish(ctx context.Context, msgs ...Message) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	var conn net.Conn
+	var err error
+	if c.useTLS {
+		dialer := tls.Dialer{Config: &tls.Config{ServerName: hostname(c.addr)}}
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Stop waiting for the broker if ctx is cancelled.
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	r := bufio.NewReader(conn)
+	if _, err := conn.Write(c.connect()); err != nil {
+		return err
+	}
+	typ, body, err := readPacket(r)
+	if err != nil {
+		return err
+	}
+	if typ != packetConnack || len(body) != 2 {
+		return fmt.Errorf("Expected CONNACK from MQTT broker, got packet type %v", typ)
+	}
+	if body[1] != 0 {
+		reason, ok := connackErrors[body[1]]
+		if !ok {
+			reason = fmt.Sprintf("code %v", body[1])
+		}
+		return fmt.Errorf("MQTT broker refused connection: %v", reason)
+	}
+
+	for i, msg := range msgs {
+		packetId := uint16(i%65535 + 1)
+		if _, err := conn.Write(publish(msg, packetId)); err != nil {
+			return err
+		}
+		typ, body, err := readPacket(r)
+		if err != nil {
+			return err
+		}
+		if typ != packetPuback || len(body) != 2 || binary.BigEndian.Uint16(body) != packetId {
+			return fmt.Errorf("Expected PUBACK for %v from MQTT broker", msg.Topic)
+		}
+	}
+	_, err = conn.Write([]byte{packetDisconnect << 4, 0})
+	return err
+}
+
+func hostname(addr string) string {
+	host, _, _ := net.SplitHostPort(addr)
+	return host
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// Adds the fixed header, which ends with the length of the rest of the
+// packet, 7 bits at a time.
+func packet(firstByte byte, body []byte) []byte {
+	b := []byte{firstByte}
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(b, body...)
+}
+
+func (c *Client) connect() []byte {
+	// Clean session, as nothing is subscribed to.
+	flags := byte(0x02)
+	if c.username != "" {
+		flags |= 0x80
+	}
+	if c.password != "" {
+		flags |= 0x40
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, 60) // Keep alive, in seconds
+	body = appendString(body, c.ClientId)
+	if c.username != "" {
+		body = appendString(body, c.username)
+	}
+	if c.password != "" {
+		body = appendString(body, c.password)
+	}
+	return packet(packetConnect<<4, body)
+}
+
+func publish(msg Message, packetId uint16) []byte {
+	// QoS 1
+	flags := byte(0x02)
+	if msg.Retain {
+		flags |= 0x01
+	}
+	body := appendString(nil, msg.Topic)
+	body = binary.BigEndian.AppendUint16(body, packetId)
+	body = append(body, msg.Payload...)
+	return packet(packetPublish<<4|flags, body)
+}
+
+// Gives the packet's type and everything after its fixed header.
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length := 0
+	for shift := 0; ; shift += 7 {
+		if shift > 21 {
+			return 0, nil, errors.New("Malformed MQTT packet length")
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= int(digit&0x7f) << shift
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return first >> 4, body, nil
+}
@@ -0,0 +1,215 @@
+package mqtt_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/mqtt"
+	"github.com/stretchr/testify/assert"
+)
+
+// Just enough of an MQTT broker to accept what Client publishes, keeping
+// retained messages like a real broker would.
+type broker struct {
+	Addr     string
+	Username string
+	Password string
+
+	mu       sync.Mutex
+	retained map[string][]byte
+	clientId string
+}
+
+func newBroker(t *testing.T) *broker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	b := &broker{Addr: l.Addr().String(), retained: make(map[string][]byte)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *broker) Retained() map[string][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := make(map[string][]byte)
+	for topic, payload := range b.retained {
+		res[topic] = payload
+	}
+	return res
+}
+
+func (b *broker) ClientId() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.clientId
+}
+
+func readString(body []byte) (string, []byte) {
+	n := binary.BigEndian.Uint16(body)
+	return string(body[2 : 2+n]), body[2+n:]
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length := 0
+	for shift := 0; ; shift += 7 {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= int(digit&0x7f) << shift
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return first, body, err
+}
+
+func (b *broker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	first, body, err := readPacket(r)
+	if err != nil || first>>4 != 1 {
+		return
+	}
+	_, body = readString(body) // Protocol name
+	flags := body[1]
+	clientId, body := readString(body[4:])
+	var username, password string
+	if flags&0x80 != 0 {
+		username, body = readString(body)
+	}
+	if flags&0x40 != 0 {
+		password, _ = readString(body)
+	}
+	if username != b.Username || password != b.Password {
+		conn.Write([]byte{0x20, 2, 0, 4})
+		return
+	}
+	b.mu.Lock()
+	b.clientId = clientId
+	b.mu.Unlock()
+	conn.Write([]byte{0x20, 2, 0, 0})
+
+	for {
+		first, body, err := readPacket(r)
+		if err != nil || first>>4 != 3 {
+			return
+		}
+		topic, rest := readString(body)
+		packetId, payload := rest[:2], rest[2:]
+		if first&0x01 != 0 {
+			b.mu.Lock()
+			b.retained[topic] = payload
+			b.mu.Unlock()
+		}
+		conn.Write(append([]byte{0x40, 2}, packetId...))
+	}
+}
+
+func TestNewClientNeedsMqttUrl(t *testing.T) {
+	for _, rawURL := range []string{"http://localhost", "localhost:1883", ""} {
+		_, err := mqtt.NewClient(rawURL)
+
+		assert.ErrorContains(t, err, "MQTT URL must start with mqtt:// or mqtts://", rawURL)
+	}
+}
+
+func TestNewClientNeedsUsernameWithPassword(t *testing.T) {
+	_, err := mqtt.NewClient("mqtt://:secret@localhost")
+
+	assert.ErrorContains(t, err, "MQTT URL must have a user name to go with the password")
+}
+
+func TestPublishRetained(t *testing.T) {
+	b := newBroker(t)
+	c, _ := mqtt.NewClient("mqtt://" + b.Addr)
+
+	err := c.Publish(context.Background(),
+		mqtt.Message{Topic: "a/b", Payload: []byte("kept"), Retain: true},
+		mqtt.Message{Topic: "a/c", Payload: []byte("not kept")},
+	)
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"a/b": []byte("kept")}, b.Retained())
+	assert.Regexp(t, `^hackney-bindicator-[0-9a-f]{4}$`, b.ClientId())
+}
+
+func TestClientIdsDiffer(t *testing.T) {
+	ids := make(map[string]bool)
+	for range 10 {
+		c, _ := mqtt.NewClient("mqtt://localhost")
+		ids[c.ClientId] = true
+	}
+
+	assert.Greater(t, len(ids), 1)
+}
+
+func TestPublishLongMessage(t *testing.T) {
+	b := newBroker(t)
+	c, _ := mqtt.NewClient("mqtt://" + b.Addr)
+	payload := make([]byte, 20000)
+
+	err := c.Publish(context.Background(), mqtt.Message{Topic: "long", Payload: payload, Retain: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, payload, b.Retained()["long"])
+}
+
+func TestPublishWithCredentials(t *testing.T) {
+	b := newBroker(t)
+	b.Username = "user"
+	b.Password = "secret"
+	c, _ := mqtt.NewClient("mqtt://user:secret@" + b.Addr)
+
+	err := c.Publish(context.Background(), mqtt.Message{Topic: "a", Payload: []byte("1"), Retain: true})
+
+	assert.Nil(t, err)
+	assert.Len(t, b.Retained(), 1)
+}
+
+func TestPublishWithWrongCredentials(t *testing.T) {
+	b := newBroker(t)
+	b.Username = "user"
+	b.Password = "secret"
+	c, _ := mqtt.NewClient("mqtt://user:wrong@" + b.Addr)
+
+	err := c.Publish(context.Background(), mqtt.Message{Topic: "a", Payload: []byte("1"), Retain: true})
+
+	assert.ErrorContains(t, err, "MQTT broker refused connection: bad user name or password")
+	assert.Empty(t, b.Retained())
+}
+
+func TestPublishTimesOut(t *testing.T) {
+	// Accepts connections but never answers.
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	c, _ := mqtt.NewClient("mqtt://" + l.Addr().String())
+	c.Timeout = time.Millisecond * 50
+
+	err := c.Publish(context.Background(), mqtt.Message{Topic: "a", Payload: []byte("1")})
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,196 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/jonboulle/clockwork"
+)
+
+type Bin struct {
+	Id             string
+	Name           string
+	Type           client.RefuseType
+	NextCollection client.Date
+}
+
+// The bins at a property, and when each is next collected.
+type Property struct {
+	Id string
+	// The property's address.
+	Name string
+	Bins []Bin
+}
+
+// Finds the bins at a property.
+type Lookup func(ctx context.Context, propertyId string) (Property, error)
+
+// Icons Home Assistant knows, for each type of refuse.
+var icons = map[client.RefuseType]string{
+	client.Food:        "mdi:food-apple",
+	client.Recycling:   "mdi:recycle",
+	client.Garden:      "mdi:leaf",
+	client.Rubbish:     "mdi:trash-can",
+	client.Glass:       "mdi:bottle-wine",
+	client.Textiles:    "mdi:tshirt-crew",
+	client.Bulky:       "mdi:sofa",
+	client.Electricals: "mdi:power-plug",
+}
+
+// MQTT topics can't have wildcards in, and Home Assistant only allows these
+// characters in the IDs it uses in discovery topics.
+var unsafeTopicChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+func topicId(s string) string {
+	return unsafeTopicChars.ReplaceAllString(s, "_")
+}
+
+// Publishes a sensor for each bin at some properties, using Home Assistant's
+// MQTT discovery, so they show up in Home Assistant without any setup there.
+// Each sensor's state is the date the bin is next collected, with how many
+// days away that is as an attribute.
+type HomeAssistant struct {
+	Client      *Client
+	Lookup      Lookup
+	PropertyIds []string
+	// Where Home Assistant looks for discovery messages. Defaults to
+	// "homeassistant", which is what Home Assistant uses unless told otherwise.
+	DiscoveryPrefix string
+	// Where sensors' states are published. Defaults to "bindicator".
+	BaseTopic string
+	// How often to publish, so that days until collection stay right. Also
+	// how soon Home Assistant hears about changes to the schedule.
+	Interval time.Duration
+	// Nil means the real clock.
+	Clock clockwork.Clock
+}
+
+func (h *HomeAssistant) clock() clockwork.Clock {
+	if h.Clock == nil {
+		return clockwork.NewRealClock()
+	}
+	return h.Clock
+}
+
+// Publishes straight away, and then every Interval until ctx is done.
+func (h *HomeAssistant) Run(ctx context.Context) error {
+	clock := h.clock()
+	for {
+		if err := h.Publish(ctx); err != nil {
+			log.Println("could not publish to MQTT:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(h.Interval):
+		}
+	}
+}
+
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+type discoveryConfig struct {
+	Name                string          `json:"name"`
+	UniqueId            string          `json:"unique_id"`
+	StateTopic          string          `json:"state_topic"`
+	ValueTemplate       string          `json:"value_template"`
+	JsonAttributesTopic string          `json:"json_attributes_topic"`
+	DeviceClass         string          `json:"device_class"`
+	Icon                string          `json:"icon"`
+	Device              discoveryDevice `json:"device"`
+}
+
+type sensorState struct {
+	NextCollection client.Date       `json:"next_collection"`
+	DaysUntil      int               `json:"days_until"`
+	Type           client.RefuseType `json:"type"`
+}
+
+// The retained discovery config and state for every bin at a property.
+func (h *HomeAssistant) messages(p Property, today client.Date) ([]Message, error) {
+	prefix := h.DiscoveryPrefix
+	if prefix == "" {
+		prefix = "homeassistant"
+	}
+	base := h.BaseTopic
+	if base == "" {
+		base = "bindicator"
+	}
+	device := discoveryDevice{
+		Identifiers:  []string{"bindicator_" + topicId(p.Id)},
+		Name:         p.Name,
+		Manufacturer: "Hackney Bindicator",
+		Model:        "Hackney Council bin collections",
+	}
+	var msgs []Message
+	for _, bin := range p.Bins {
+		id := "bindicator_" + topicId(p.Id) + "_" + topicId(bin.Id)
+		stateTopic := base + "/" + topicId(p.Id) + "/" + topicId(bin.Id)
+		icon, ok := icons[bin.Type]
+		if !ok {
+			icon = "mdi:delete"
+		}
+		config, err := json.Marshal(discoveryConfig{
+			Name:                bin.Name,
+			UniqueId:            id,
+			StateTopic:          stateTopic,
+			ValueTemplate:       "{{ value_json.next_collection }}",
+			JsonAttributesTopic: stateTopic,
+			DeviceClass:         "date",
+			Icon:                icon,
+			Device:              device,
+		})
+		if err != nil {
+			return nil, err
+		}
+		days := int(bin.NextCollection.In(time.UTC).Sub(today.In(time.UTC)).Hours() / 24)
+		state, err := json.Marshal(sensorState{NextCollection: bin.NextCollection, DaysUntil: days, Type: bin.Type})
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs,
+			Message{Topic: prefix + "/sensor/" + id + "/config", Payload: config, Retain: true},
+			Message{Topic: stateTopic, Payload: state, Retain: true},
+		)
+	}
+	return msgs, nil
+}
+
+// Publishes every bin at every property that can be looked up, all in one
+// go. Properties that can't be looked up keep their last states.
+func (h *HomeAssistant) Publish(ctx context.Context) error {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		return err
+	}
+	today := client.DateOf(h.clock().Now().In(london))
+	var msgs []Message
+	var errs []error
+	for _, propertyId := range h.PropertyIds {
+		p, err := h.Lookup(ctx, propertyId)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		m, err := h.messages(p, today)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		msgs = append(msgs, m...)
+	}
+	if len(msgs) > 0 {
+		errs = append(errs, h.Client.Publish(ctx, msgs...))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,120 @@
+package mqtt_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/mqtt"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func lookup(ctx context.Context, propertyId string) (mqtt.Property, error) {
+	if propertyId != "property_id" {
+		return mqtt.Property{}, errors.New("No such property")
+	}
+	return mqtt.Property{
+		Id:   "property_id",
+		Name: "29 ACACIA AVENUE",
+		Bins: []mqtt.Bin{
+			{Id: "food", Name: "Food caddy", Type: client.Food, NextCollection: client.NewDate(2024, time.January, 1)},
+			{Id: "bin/1", Name: "Mystery bin", NextCollection: client.NewDate(2024, time.January, 8)},
+		},
+	}, nil
+}
+
+func homeAssistant(t *testing.T) (*mqtt.HomeAssistant, *broker, *clockwork.FakeClock) {
+	b := newBroker(t)
+	c, _ := mqtt.NewClient("mqtt://" + b.Addr)
+	london, _ := time.LoadLocation("Europe/London")
+	clock := clockwork.NewFakeClockAt(time.Date(2023, 12, 31, 23, 30, 0, 0, london))
+	return &mqtt.HomeAssistant{
+		Client:      c,
+		Lookup:      lookup,
+		PropertyIds: []string{"property_id"},
+		Interval:    time.Hour,
+		Clock:       clock,
+	}, b, clock
+}
+
+func TestPublishHomeAssistantDiscovery(t *testing.T) {
+	h, b, _ := homeAssistant(t)
+
+	err := h.Publish(context.Background())
+
+	assert.Nil(t, err)
+	retained := b.Retained()
+	assert.Len(t, retained, 4)
+	assert.JSONEq(t, `{
+		"name": "Food caddy",
+		"unique_id": "bindicator_property_id_food",
+		"state_topic": "bindicator/property_id/food",
+		"value_template": "{{ value_json.next_collection }}",
+		"json_attributes_topic": "bindicator/property_id/food",
+		"device_class": "date",
+		"icon": "mdi:food-apple",
+		"device": {
+			"identifiers": ["bindicator_property_id"],
+			"name": "29 ACACIA AVENUE",
+			"manufacturer": "Hackney Bindicator",
+			"model": "Hackney Council bin collections"
+		}
+	}`, string(retained["homeassistant/sensor/bindicator_property_id_food/config"]))
+	assert.JSONEq(t, `{"next_collection": "2024-01-01", "days_until": 1, "type": "food"}`,
+		string(retained["bindicator/property_id/food"]))
+}
+
+func TestPublishHomeAssistantUnsafeIds(t *testing.T) {
+	h, b, _ := homeAssistant(t)
+
+	h.Publish(context.Background())
+
+	retained := b.Retained()
+	assert.Contains(t, string(retained["homeassistant/sensor/bindicator_property_id_bin_1/config"]), `"icon":"mdi:delete"`)
+	assert.JSONEq(t, `{"next_collection": "2024-01-08", "days_until": 8, "type": "unknown"}`,
+		string(retained["bindicator/property_id/bin_1"]))
+}
+
+func TestPublishHomeAssistantOtherTopics(t *testing.T) {
+	h, b, _ := homeAssistant(t)
+	h.DiscoveryPrefix = "ha"
+	h.BaseTopic = "bins"
+
+	h.Publish(context.Background())
+
+	retained := b.Retained()
+	assert.Contains(t, retained, "ha/sensor/bindicator_property_id_food/config")
+	assert.Contains(t, string(retained["ha/sensor/bindicator_property_id_food/config"]), `"state_topic":"bins/property_id/food"`)
+	assert.Contains(t, retained, "bins/property_id/food")
+}
+
+func TestPublishHomeAssistantSkipsPropertiesNotFound(t *testing.T) {
+	h, b, _ := homeAssistant(t)
+	h.PropertyIds = []string{"missing", "property_id"}
+
+	err := h.Publish(context.Background())
+
+	assert.ErrorContains(t, err, "No such property")
+	assert.Len(t, b.Retained(), 4)
+}
+
+func TestRunHomeAssistantRepublishes(t *testing.T) {
+	h, b, clock := homeAssistant(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- h.Run(ctx) }()
+
+	clock.BlockUntilContext(ctx, 1)
+	assert.JSONEq(t, `{"next_collection": "2024-01-01", "days_until": 1, "type": "food"}`,
+		string(b.Retained()["bindicator/property_id/food"]))
+	clock.Advance(time.Hour)
+	clock.BlockUntilContext(ctx, 1)
+	cancel()
+
+	assert.JSONEq(t, `{"next_collection": "2024-01-01", "days_until": 0, "type": "food"}`,
+		string(b.Retained()["bindicator/property_id/food"]))
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,65 @@
+package reminder
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+)
+
+// What is collected from a property on one day.
+type Collection struct {
+	PropertyId string
+	// The property's address.
+	Name    string
+	Date    client.Date
+	Summary string
+	Types   []string
+	Bins    []Bin
+}
+
+type Bin struct {
+	Id   string
+	Name string
+	Type string
+}
+
+// Finds what is collected from a property on a day. Returns false if nothing is.
+type Lookup func(ctx context.Context, propertyId string, date client.Date) (Collection, bool, error)
+
+// Reminders go out the evening before collections.
+func tomorrow(now time.Time) (client.Date, error) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		return client.Date{}, err
+	}
+	return client.DateOf(now.In(london)).AddDays(1), nil
+}
+
+// Looks up each property at most once, however many subscriptions it has.
+func memoize(lookup Lookup) Lookup {
+	type result struct {
+		collection Collection
+		found      bool
+		err        error
+	}
+	results := map[string]result{}
+	return func(ctx context.Context, propertyId string, date client.Date) (Collection, bool, error) {
+		key := propertyId + " " + date.String()
+		if r, ok := results[key]; ok {
+			return r.collection, r.found, r.err
+		}
+		collection, found, err := lookup(ctx, propertyId, date)
+		results[key] = result{collection, found, err}
+		return collection, found, err
+	}
+}
+
+// A random string that can't be guessed, for IDs and secrets.
+func NewToken(bytes int) string {
+	b := make([]byte, bytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
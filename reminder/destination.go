@@ -0,0 +1,100 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// Anyone can subscribe, so subscriptions may only send to the public
+// internet. Otherwise they could be used to reach services inside the network
+// this runs in.
+var ErrPrivateAddress = errors.New("Subscriptions can only send to public internet addresses")
+
+var notPublic = []netip.Prefix{
+	// "This network", which some systems treat as the local host.
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// Carrier-grade NAT, which some clouds use for their own services.
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// Reports whether addr is on the public internet, rather than being a
+// loopback, private, link-local, multicast or unspecified address.
+func IsPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range notPublic {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Finds the addresses of a host.
+type LookupIP func(ctx context.Context, host string) ([]netip.Addr, error)
+
+// Checks that every address host has is public, so that mistakes show up
+// when subscribing. The client from NewPublicHttpClient checks again on every
+// connection, in case the host's addresses change. Nil lookup means DNS.
+func CheckPublicHost(ctx context.Context, lookup LookupIP, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !IsPublic(addr) {
+			return ErrPrivateAddress
+		}
+		return nil
+	}
+	if lookup == nil {
+		lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+			return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		}
+	}
+	addrs, err := lookup(ctx, host)
+	if err != nil {
+		return fmt.Errorf("Could not find %v: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !IsPublic(addr) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// An http.Client for sending to subscribers. It refuses to connect to
+// addresses that aren't public, checking each connection as it is made so
+// that DNS answers changing after subscribing can't get round it. Redirects
+// aren't followed, as they could point anywhere.
+func NewPublicHttpClient(timeout time.Duration) http.Client {
+	dialer := &net.Dialer{
+		Timeout: time.Second * 30,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !IsPublic(addrPort.Addr()) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Otherwise the proxy would be checked, not where requests end up.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
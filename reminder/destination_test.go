@@ -0,0 +1,79 @@
+package reminder_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.215.14":          true,
+		"2606:2800:21f:cb07::1":  true,
+		"100.128.0.1":            true,
+		"127.0.0.1":              false,
+		"::1":                    false,
+		"10.1.2.3":               false,
+		"172.16.0.1":             false,
+		"192.168.1.1":            false,
+		"fd00::1":                false,
+		"169.254.169.254":        false,
+		"fe80::1":                false,
+		"0.0.0.0":                false,
+		"0.1.2.3":                false,
+		"::":                     false,
+		"100.100.100.200":        false,
+		"224.0.0.1":              false,
+		"::ffff:169.254.169.254": false,
+	}
+	for addr, public := range tests {
+		assert.Equal(t, public, reminder.IsPublic(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestCheckPublicHost(t *testing.T) {
+	lookup := func(ctx context.Context, host string) ([]netip.Addr, error) {
+		switch host {
+		case "public.example.com":
+			return []netip.Addr{netip.MustParseAddr("93.184.215.14")}, nil
+		case "rebound.example.com":
+			return []netip.Addr{netip.MustParseAddr("93.184.215.14"), netip.MustParseAddr("127.0.0.1")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	assert.Nil(t, reminder.CheckPublicHost(context.Background(), lookup, "public.example.com"))
+	assert.Nil(t, reminder.CheckPublicHost(context.Background(), lookup, "93.184.215.14"))
+	assert.ErrorIs(t, reminder.CheckPublicHost(context.Background(), lookup, "rebound.example.com"), reminder.ErrPrivateAddress)
+	assert.ErrorIs(t, reminder.CheckPublicHost(context.Background(), lookup, "127.0.0.1"), reminder.ErrPrivateAddress)
+	assert.ErrorContains(t, reminder.CheckPublicHost(context.Background(), lookup, "missing.example.com"), "Could not find missing.example.com")
+}
+
+func TestPublicHttpClientRefusesPrivateAddresses(t *testing.T) {
+	var called bool
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer svr.Close()
+	httpClient := reminder.NewPublicHttpClient(time.Second)
+
+	_, err := httpClient.Get(svr.URL)
+
+	assert.ErrorIs(t, err, reminder.ErrPrivateAddress)
+	assert.False(t, called)
+}
+
+func TestPublicHttpClientDoesNotFollowRedirects(t *testing.T) {
+	httpClient := reminder.NewPublicHttpClient(time.Second)
+
+	err := httpClient.CheckRedirect(nil, nil)
+
+	assert.ErrorIs(t, err, http.ErrUseLastResponse)
+}
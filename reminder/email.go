@@ -0,0 +1,246 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"embed"
+	"encoding/base64"
+	"errors"
+	htmltemplate "html/template"
+	"log"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var (
+	textTemplates = template.Must(template.ParseFS(templates, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templates, "templates/*.html"))
+)
+
+// Where the links in emails go. Handled by handler.EmailSubscriptionHandler.
+const (
+	ConfirmPath     = "/subscriptions/email/confirm"
+	UnsubscribePath = "/subscriptions/email/unsubscribe"
+)
+
+// Unconfirmed subscriptions are forgotten after this long.
+const confirmWithin = 7 * 24 * time.Hour
+
+// Subscribing again sooner than this doesn't send another confirmation, so
+// that nobody can use it to flood someone's inbox.
+const resendAfter = time.Hour
+
+// Asks for an email the evening before each collection at a property. Nothing
+// is sent until the address has been confirmed, apart from the email asking
+// for that.
+type EmailSubscription struct {
+	Id         string
+	PropertyId string
+	// The property's address.
+	Name      string
+	Email     string
+	Confirmed bool
+	Created   time.Time
+	// When the last email asking to confirm was sent.
+	ConfirmationSent time.Time
+}
+
+// The token in a link was not signed by us, or its subscription has gone.
+var ErrBadLink = errors.New("This link is not valid, or has expired")
+
+// Subscribes people to emails, and sends them the evening before each
+// collection.
+type EmailReminders struct {
+	Store  *Store[EmailSubscription]
+	Lookup Lookup
+	Mailer Mailer
+	// Signs the tokens in links, so that they can't be made up. Changing it
+	// breaks the links in every email sent before.
+	Key []byte
+	// Where this API is served, e.g. https://bins.example.com, for links.
+	BaseUrl string
+	// Nil means the real clock.
+	Clock clockwork.Clock
+}
+
+func (e *EmailReminders) clock() clockwork.Clock {
+	if e.Clock == nil {
+		return clockwork.NewRealClock()
+	}
+	return e.Clock
+}
+
+func (e *EmailReminders) signature(purpose, id string) []byte {
+	mac := hmac.New(sha256.New, e.Key)
+	mac.Write([]byte(purpose + ":" + id))
+	return mac.Sum(nil)
+}
+
+// Tokens are the subscription ID and a signature, so that nothing else needs
+// storing to check them. The purpose stops a confirmation link being used
+// to unsubscribe, and vice versa.
+func (e *EmailReminders) token(purpose, id string) string {
+	return id + "." + base64.RawURLEncoding.EncodeToString(e.signature(purpose, id))
+}
+
+func (e *EmailReminders) verify(purpose, token string) (string, bool) {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return "", false
+	}
+	return id, hmac.Equal(got, e.signature(purpose, id))
+}
+
+func (e *EmailReminders) link(path, purpose, id string) string {
+	return strings.TrimSuffix(e.BaseUrl, "/") + path + "?" + url.Values{"token": {e.token(purpose, id)}}.Encode()
+}
+
+// What the templates are given.
+type emailData struct {
+	Collection
+	// When the collection is, for people.
+	Day  string
+	Link string
+}
+
+func render(name string, data emailData) (string, string, error) {
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return "", "", err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+// Emails a link to confirm the subscription. Asking again for a subscription
+// that hasn't been confirmed yet sends another link. Asking again for one
+// that has does nothing, so as not to tell anyone who else has subscribed.
+func (e *EmailReminders) Subscribe(ctx context.Context, propertyId, name, email string) error {
+	now := e.clock().Now()
+	sub := EmailSubscription{Id: NewToken(16), PropertyId: propertyId, Name: name, Email: email, Created: now, ConfirmationSent: now}
+	for _, s := range e.Store.All() {
+		if s.Email != email || s.PropertyId != propertyId {
+			continue
+		}
+		if s.Confirmed || now.Sub(s.ConfirmationSent) < resendAfter {
+			return nil
+		}
+		sub.Id = s.Id
+	}
+	if err := e.Store.Put(sub.Id, sub); err != nil {
+		return err
+	}
+
+	text, html, err := render("confirm", emailData{
+		Collection: Collection{PropertyId: propertyId, Name: name},
+		Link:       e.link(ConfirmPath, "confirm", sub.Id),
+	})
+	if err != nil {
+		return err
+	}
+	return e.Mailer.Send(ctx, Message{
+		To:      email,
+		Subject: "Confirm your bin collection reminders",
+		Date:    now,
+		Text:    text,
+		HTML:    html,
+	})
+}
+
+func (e *EmailReminders) Confirm(token string) (EmailSubscription, error) {
+	id, ok := e.verify("confirm", token)
+	if !ok {
+		return EmailSubscription{}, ErrBadLink
+	}
+	sub, found := e.Store.Get(id)
+	if !found {
+		return EmailSubscription{}, ErrBadLink
+	}
+	if sub.Confirmed {
+		return sub, nil
+	}
+	if e.clock().Since(sub.Created) > confirmWithin {
+		return EmailSubscription{}, ErrBadLink
+	}
+	sub.Confirmed = true
+	return sub, e.Store.Put(sub.Id, sub)
+}
+
+// It is not an error to unsubscribe twice.
+func (e *EmailReminders) Unsubscribe(token string) error {
+	id, ok := e.verify("unsubscribe", token)
+	if !ok {
+		return ErrBadLink
+	}
+	return e.Store.Delete(id)
+}
+
+// Meant to be run by a Scheduler in the evening. Also forgets subscriptions
+// that were never confirmed.
+func (e *EmailReminders) Send(ctx context.Context, now time.Time) {
+	date, err := tomorrow(now)
+	if err != nil {
+		log.Println("could not send emails:", err)
+		return
+	}
+	london, _ := time.LoadLocation("Europe/London")
+	lookup := memoize(e.Lookup)
+	for _, sub := range e.Store.All() {
+		if !sub.Confirmed {
+			if now.Sub(sub.Created) > confirmWithin {
+				if err := e.Store.Delete(sub.Id); err != nil {
+					log.Println("could not forget email subscription", sub.Id, err)
+				}
+			}
+			continue
+		}
+		collection, found, err := lookup(ctx, sub.PropertyId, date)
+		if err != nil {
+			log.Println("could not look up collections for email", sub.Id, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		unsubscribe := e.link(UnsubscribePath, "unsubscribe", sub.Id)
+		text, html, err := render("reminder", emailData{
+			Collection: collection,
+			Day:        collection.Date.In(london).Format("Monday 2 January"),
+			Link:       unsubscribe,
+		})
+		if err != nil {
+			log.Println("could not write email", sub.Id, err)
+			continue
+		}
+		err = e.Mailer.Send(ctx, Message{
+			To:      sub.Email,
+			Subject: "Put out: " + strings.Join(collection.Types, ", "),
+			Date:    now,
+			Text:    text,
+			HTML:    html,
+			// Lets mail clients show their own unsubscribe button (RFC 8058).
+			Headers: map[string]string{
+				"List-Unsubscribe":      "<" + unsubscribe + ">",
+				"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+			},
+		})
+		if err != nil {
+			log.Println("could not send email", sub.Id, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package reminder_test
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+const Email = "resident@example.com"
+
+func emailReminders(t *testing.T, lookup reminder.Lookup) (*reminder.EmailReminders, <-chan sentMail, *clockwork.FakeClock) {
+	addr, mails := smtpServer(t)
+	store, _ := reminder.NewStore[reminder.EmailSubscription]("")
+	clock := clockwork.NewFakeClockAt(Evening.Add(-time.Hour * 24))
+	return &reminder.EmailReminders{
+		Store:   store,
+		Lookup:  lookup,
+		Mailer:  reminder.SMTPMailer{Addr: addr, From: "bins@example.com", Timeout: time.Second},
+		Key:     []byte("key"),
+		BaseUrl: "https://bins.example.com/",
+		Clock:   clock,
+	}, mails, clock
+}
+
+var linkPattern = regexp.MustCompile(`https://bins\.example\.com(/\S+)\?token=(\S+)`)
+
+// The path and token in the first link in an email.
+func link(t *testing.T, text string) (string, string) {
+	match := linkPattern.FindStringSubmatch(text)
+	if match == nil {
+		t.Fatal("no link in", text)
+	}
+	token, _ := url.QueryUnescape(match[2])
+	return match[1], token
+}
+
+func mailText(t *testing.T, mail sentMail) string {
+	_, text, _ := readMail(t, mail.Data)
+	return text
+}
+
+func TestEmailSubscriptionLifecycle(t *testing.T) {
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	e, mails, _ := emailReminders(t, lookup)
+
+	err := e.Subscribe(context.Background(), PropertyId, "29 ACACIA AVENUE", Email)
+	assert.Nil(t, err)
+	confirmation := <-mails
+	assert.Equal(t, []string{Email}, confirmation.To)
+	header, text, html := readMail(t, confirmation.Data)
+	assert.Equal(t, "Confirm your bin collection reminders", header.Get("Subject"))
+	assert.Contains(t, text, "29 ACACIA AVENUE")
+	path, token := link(t, text)
+	assert.Equal(t, reminder.ConfirmPath, path)
+	assert.Contains(t, html, `href="https://bins.example.com/subscriptions/email/confirm?token=`)
+
+	sub, err := e.Confirm(token)
+	assert.Nil(t, err)
+	assert.True(t, sub.Confirmed)
+	assert.True(t, e.Store.All()[0].Confirmed)
+
+	e.Send(context.Background(), Evening)
+	header, text, html = readMail(t, (<-mails).Data)
+	assert.Equal(t, "Put out: recycling, food", header.Get("Subject"))
+	assert.Equal(t, "List-Unsubscribe=One-Click", header.Get("List-Unsubscribe-Post"))
+	assert.Contains(t, text, "Recycling and food waste will be collected on Tuesday 2 January")
+	assert.Contains(t, text, "- Recycling sack\n- Food caddy")
+	assert.Contains(t, html, "<li>Food caddy</li>")
+	path, token = link(t, text)
+	assert.Equal(t, reminder.UnsubscribePath, path)
+	assert.Equal(t, "<https://bins.example.com"+path+"?token="+url.QueryEscape(token)+">", header.Get("List-Unsubscribe"))
+
+	assert.Nil(t, e.Unsubscribe(token))
+	assert.Empty(t, e.Store.All())
+	assert.Nil(t, e.Unsubscribe(token))
+}
+
+func TestNoEmailUntilConfirmed(t *testing.T) {
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	e, mails, _ := emailReminders(t, lookup)
+	e.Subscribe(context.Background(), PropertyId, "29 ACACIA AVENUE", Email)
+	<-mails
+
+	e.Send(context.Background(), Evening)
+
+	assert.Empty(t, mails)
+	assert.Len(t, e.Store.All(), 1)
+}
+
+func TestNoEmailWithoutCollection(t *testing.T) {
+	lookup, _ := lookupReturning(reminder.Collection{}, false, nil)
+	e, mails, _ := emailReminders(t, lookup)
+	e.Store.Put("a", reminder.EmailSubscription{Id: "a", PropertyId: PropertyId, Email: Email, Confirmed: true})
+
+	e.Send(context.Background(), Evening)
+
+	assert.Empty(t, mails)
+}
+
+func TestUnconfirmedSubscriptionsExpire(t *testing.T) {
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	e, mails, clock := emailReminders(t, lookup)
+	e.Subscribe(context.Background(), PropertyId, "29 ACACIA AVENUE", Email)
+	_, token := link(t, mailText(t, <-mails))
+
+	clock.Advance(time.Hour * 24 * 8)
+	e.Send(context.Background(), clock.Now())
+
+	assert.Empty(t, e.Store.All())
+	_, err := e.Confirm(token)
+	assert.ErrorIs(t, err, reminder.ErrBadLink)
+}
+
+func TestResubscribingSendsAnotherConfirmation(t *testing.T) {
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	e, mails, clock := emailReminders(t, lookup)
+	e.Subscribe(context.Background(), PropertyId, "29 ACACIA AVENUE", Email)
+	_, first := link(t, mailText(t, <-mails))
+
+	clock.Advance(time.Hour)
+	e.Subscribe(context.Background(), PropertyId, "29 ACACIA AVENUE", Email)
+	_, second := link(t, mailText(t, <-mails))
+
+	assert.Equal(t, first, second)
+	assert.Len(t, e.Store.All(), 1)
+}
+
+func TestResubscribingTooSoonSendsNothing(t *testing.T) {
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	e, mails, clock := emailReminders(t, lookup)
+	e.Subscribe(context.Background(), PropertyId, "29 ACACIA AVENUE", Email)
+	<-mails
+
+	clock.Advance(time.Minute * 59)
+	err := e.Subscribe(context.Background(), PropertyId, "29 ACACIA AVENUE", Email)
+
+	assert.Nil(t, err)
+	assert.Empty(t, mails)
+	assert.Len(t, e.Store.All(), 1)
+}
+
+func TestResubscribingOnceConfirmedSendsNothing(t *testing.T) {
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	e, mails, _ := emailReminders(t, lookup)
+	e.Store.Put("a", reminder.EmailSubscription{Id: "a", PropertyId: PropertyId, Email: Email, Confirmed: true})
+
+	err := e.Subscribe(context.Background(), PropertyId, "29 ACACIA AVENUE", Email)
+
+	assert.Nil(t, err)
+	assert.Empty(t, mails)
+	assert.Len(t, e.Store.All(), 1)
+}
+
+func TestBadLinks(t *testing.T) {
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	e, mails, _ := emailReminders(t, lookup)
+	e.Subscribe(context.Background(), PropertyId, "29 ACACIA AVENUE", Email)
+	_, confirm := link(t, mailText(t, <-mails))
+	id := e.Store.All()[0].Id
+
+	for _, token := range []string{"", id, id + ".", id + ".bad", "other" + confirm[len(id):]} {
+		_, err := e.Confirm(token)
+		assert.ErrorIs(t, err, reminder.ErrBadLink, token)
+		assert.ErrorIs(t, e.Unsubscribe(token), reminder.ErrBadLink, token)
+	}
+	// Confirmation links can't be used to unsubscribe.
+	assert.ErrorIs(t, e.Unsubscribe(confirm), reminder.ErrBadLink)
+	assert.Len(t, e.Store.All(), 1)
+	// Nor can tokens signed with another key.
+	e.Key = []byte("other key")
+	_, err := e.Confirm(confirm)
+	assert.ErrorIs(t, err, reminder.ErrBadLink)
+}
@@ -0,0 +1,161 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strings"
+	"time"
+)
+
+// An email with both plain text and HTML versions of its body.
+type Message struct {
+	To      string
+	Subject string
+	Date    time.Time
+	Text    string
+	HTML    string
+	// Any others, such as List-Unsubscribe.
+	Headers map[string]string
+}
+
+// Sends emails.
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Sends emails through an SMTP relay. Uses STARTTLS whenever the relay
+// offers it.
+type SMTPMailer struct {
+	// The relay's host and port.
+	Addr string
+	// Leave empty if the relay doesn't need logging in to.
+	Username string
+	Password string
+	// Who emails come from.
+	From string
+	// Zero means no timeout.
+	Timeout time.Duration
+}
+
+func (m SMTPMailer) Send(ctx context.Context, msg Message) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("Bad sender address: %w", err)
+	}
+	body, err := msg.bytes(from)
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return err
+	}
+	if m.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Timeout)
+		defer cancel()
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if m.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.Username, m.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// Encodes the message as multipart/alternative, with the plain text first so
+// that mail clients prefer the HTML.
+func (msg Message) bytes(from *mail.Address) ([]byte, error) {
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	headers := map[string]string{
+		"From":         from.String(),
+		"To":           msg.To,
+		"Subject":      mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date":         msg.Date.Format(time.RFC1123Z),
+		"Message-ID":   fmt.Sprintf("<%v@%v>", NewToken(16), domain),
+		"MIME-Version": "1.0",
+		"Content-Type": mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": parts.Boundary()}),
+	}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	var head bytes.Buffer
+	for _, k := range slices.Sorted(maps.Keys(headers)) {
+		fmt.Fprintf(&head, "%v: %v\r\n", k, headers[k])
+	}
+	head.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
@@ -0,0 +1,174 @@
+package reminder_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+	"github.com/stretchr/testify/assert"
+)
+
+type sentMail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Stands in for an SMTP relay, accepting mail for anyone except addresses
+// starting with "reject".
+func smtpServer(t *testing.T) (string, <-chan sentMail) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	mails := make(chan sentMail, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, mails)
+		}
+	}()
+	return listener.Addr().String(), mails
+}
+
+func serveSMTP(conn net.Conn, mails chan<- sentMail) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+	var mail sentMail
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 localhost")
+		case "MAIL":
+			mail = sentMail{From: strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")}
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			to := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			if strings.HasPrefix(to, "reject") {
+				text.PrintfLine("550 No such user")
+				continue
+			}
+			mail.To = append(mail.To, to)
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.Data = string(data)
+			mails <- mail
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// The headers, and the plain text and HTML parts.
+func readMail(t *testing.T, data string) (mail.Header, string, string) {
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "multipart/alternative", mediaType)
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	bodies := map[string]string{}
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(part)
+		bodies[part.Header.Get("Content-Type")] = string(body)
+	}
+	return msg.Header, bodies["text/plain; charset=utf-8"], bodies["text/html; charset=utf-8"]
+}
+
+func TestSMTPMailerSends(t *testing.T) {
+	addr, mails := smtpServer(t)
+	mailer := reminder.SMTPMailer{Addr: addr, From: "Bindicator <bins@example.com>", Timeout: time.Second}
+
+	err := mailer.Send(context.Background(), reminder.Message{
+		To:      "resident@example.com",
+		Subject: "Put out: recycling, food",
+		Date:    Evening,
+		Text:    "Put your bins out tonight. The line is long enough that quoted-printable has to wrap it somewhere.",
+		HTML:    "<p>Put your bins out tonight.</p>",
+		Headers: map[string]string{"List-Unsubscribe": "<https://example.com/unsubscribe>"},
+	})
+
+	assert.Nil(t, err)
+	sent := <-mails
+	assert.Equal(t, "bins@example.com", sent.From)
+	assert.Equal(t, []string{"resident@example.com"}, sent.To)
+	header, text, html := readMail(t, sent.Data)
+	assert.Equal(t, `"Bindicator" <bins@example.com>`, header.Get("From"))
+	assert.Equal(t, "resident@example.com", header.Get("To"))
+	assert.Equal(t, "Put out: recycling, food", header.Get("Subject"))
+	assert.Equal(t, "Mon, 01 Jan 2024 18:00:00 +0000", header.Get("Date"))
+	assert.Equal(t, "<https://example.com/unsubscribe>", header.Get("List-Unsubscribe"))
+	assert.True(t, strings.HasSuffix(header.Get("Message-ID"), "@example.com>"))
+	assert.Equal(t, "Put your bins out tonight. The line is long enough that quoted-printable has to wrap it somewhere.", text)
+	assert.Equal(t, "<p>Put your bins out tonight.</p>", html)
+}
+
+func TestSMTPMailerEncodesSubject(t *testing.T) {
+	addr, mails := smtpServer(t)
+	mailer := reminder.SMTPMailer{Addr: addr, From: "bins@example.com"}
+
+	mailer.Send(context.Background(), reminder.Message{To: "resident@example.com", Subject: "Put out: glass – today"})
+
+	header, _, _ := readMail(t, (<-mails).Data)
+	subject, _ := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+	assert.Equal(t, "Put out: glass – today", subject)
+}
+
+func TestSMTPMailerRejectedRecipient(t *testing.T) {
+	addr, mails := smtpServer(t)
+	mailer := reminder.SMTPMailer{Addr: addr, From: "bins@example.com"}
+
+	err := mailer.Send(context.Background(), reminder.Message{To: "rejected@example.com"})
+
+	assert.ErrorContains(t, err, "No such user")
+	assert.Empty(t, mails)
+}
+
+func TestSMTPMailerBadSender(t *testing.T) {
+	addr, mails := smtpServer(t)
+	mailer := reminder.SMTPMailer{Addr: addr, From: "not an address"}
+
+	err := mailer.Send(context.Background(), reminder.Message{To: "resident@example.com"})
+
+	assert.ErrorContains(t, err, "Bad sender address")
+	assert.Empty(t, mails)
+}
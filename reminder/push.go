@@ -0,0 +1,237 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/jonboulle/clockwork"
+)
+
+// Kinds of self-hosted push notification server.
+const (
+	// ntfy.sh, or a server like it. Url is the topic, e.g. https://ntfy.sh/bins.
+	Ntfy = "ntfy"
+	// Gotify. Url is the server's message endpoint, e.g.
+	// https://gotify.example.com/message.
+	Gotify = "gotify"
+)
+
+// A time of day in London, written like 18:00.
+type TimeOfDay struct {
+	Hour   int
+	Minute int
+}
+
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return TimeOfDay{}, fmt.Errorf("Not a time of day: %q", s)
+	}
+	return TimeOfDay{Hour: t.Hour(), Minute: t.Minute()}, nil
+}
+
+func (t TimeOfDay) before(other TimeOfDay) bool {
+	return t.Hour < other.Hour || t.Hour == other.Hour && t.Minute < other.Minute
+}
+
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", t.Hour, t.Minute)
+}
+
+func (t TimeOfDay) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *TimeOfDay) UnmarshalText(text []byte) error {
+	parsed, err := ParseTimeOfDay(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// Asks for a notification to be pushed to an ntfy or Gotify server the day
+// before each collection at a property.
+type PushSubscription struct {
+	Id         string
+	PropertyId string
+	Service    string
+	Url        string
+	// Sent as a bearer token to ntfy, or as the app token to Gotify. Can be
+	// left empty for ntfy topics that anyone can publish to.
+	Token string
+	// When to send the notification on the day before the collection.
+	Time TimeOfDay
+	// Only these types of refuse are mentioned, and nothing is sent if none
+	// of them are being collected. Empty means every type.
+	Types []client.RefuseType
+	// Needed to unsubscribe.
+	Secret  string
+	Created time.Time
+	// The last day a notification was due, so that clocks going back can't
+	// send it twice.
+	LastSent client.Date `json:",omitzero"`
+}
+
+// Which of a collection's types of refuse the subscriber wants to hear about.
+func (s PushSubscription) types(c Collection) []string {
+	if len(s.Types) == 0 {
+		return c.Types
+	}
+	var res []string
+	for _, t := range c.Types {
+		if slices.ContainsFunc(s.Types, func(r client.RefuseType) bool { return r.String() == t }) {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// Pushes notifications at the time each subscriber asked for.
+type PushNotifier struct {
+	Store      *Store[PushSubscription]
+	Lookup     Lookup
+	HttpClient http.Client
+	// Nil means the real clock.
+	Clock clockwork.Clock
+	// Most attempts at each notification, including the first. Zero means one.
+	MaxAttempts int
+	// How long to wait before the first retry. Doubles after each one.
+	Backoff time.Duration
+}
+
+func (p *PushNotifier) clock() clockwork.Clock {
+	if p.Clock == nil {
+		return clockwork.NewRealClock()
+	}
+	return p.Clock
+}
+
+// Checks at the start of every minute for subscriptions that are due, until
+// ctx is done, then waits for notifications still being sent. Each minute's
+// are sent in the background, so that a slow or failing server can't hold up
+// the minutes after it.
+func (p *PushNotifier) Run(ctx context.Context) error {
+	clock := p.clock()
+	due := clock.Now().Truncate(time.Minute)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		due = due.Add(time.Minute)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(due.Sub(clock.Now())):
+		}
+		now := due
+		wg.Go(func() { p.Send(ctx, now) })
+	}
+}
+
+// Notifies every subscription due by now, and not already notified today,
+// about tomorrow's collection, and waits for them all to succeed or give up.
+// Subscriptions are due once the time they asked for has passed, so that
+// times skipped when the clocks go forward are still sent.
+func (p *PushNotifier) Send(ctx context.Context, now time.Time) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		log.Println("could not push notifications:", err)
+		return
+	}
+	now = now.In(london)
+	at := TimeOfDay{Hour: now.Hour(), Minute: now.Minute()}
+	today := client.DateOf(now)
+	date := today.AddDays(1)
+	lookup := memoize(p.Lookup)
+	subs := make(map[string]PushSubscription)
+	messages := make(map[string]string)
+	var ids []string
+	for _, sub := range p.Store.All() {
+		if at.before(sub.Time) || sub.LastSent == today {
+			continue
+		}
+		collection, found, err := lookup(ctx, sub.PropertyId, date)
+		if err != nil {
+			// Tried again the next minute.
+			log.Println("could not look up collections for push", sub.Id, err)
+			continue
+		}
+		subs[sub.Id] = sub
+		ids = append(ids, sub.Id)
+		if types := sub.types(collection); found && len(types) > 0 {
+			messages[sub.Id] = "Put out: " + strings.Join(types, ", ")
+		}
+	}
+	// Claimed all at once before sending, in case the next minute's Send
+	// starts while this one is still going.
+	claimed, err := p.Store.Update(ids, func(sub PushSubscription) (PushSubscription, bool) {
+		if sub.LastSent == today {
+			return sub, false
+		}
+		sub.LastSent = today
+		return sub, true
+	})
+	if err != nil {
+		log.Println("could not record pushes:", err)
+		return
+	}
+	var wg sync.WaitGroup
+	for _, id := range claimed {
+		sub := subs[id]
+		message, ok := messages[id]
+		if !ok {
+			continue
+		}
+		wg.Go(func() {
+			err := retry(ctx, p.clock(), p.MaxAttempts, p.Backoff, func() (bool, error) {
+				return p.push(ctx, sub, "Bins tomorrow", message)
+			})
+			if err != nil {
+				log.Println("could not push notification", sub.Id, err)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func (p *PushNotifier) push(ctx context.Context, sub PushSubscription, title, message string) (bool, error) {
+	var req *http.Request
+	var err error
+	switch sub.Service {
+	case Gotify:
+		body, _ := json.Marshal(struct {
+			Title    string `json:"title"`
+			Message  string `json:"message"`
+			Priority int    `json:"priority"`
+		}{title, message, 5})
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, sub.Url, bytes.NewReader(body))
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Gotify-Key", sub.Token)
+	default:
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, sub.Url, strings.NewReader(message))
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		req.Header.Set("Title", title)
+		req.Header.Set("Tags", "wastebasket")
+		if sub.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+sub.Token)
+		}
+	}
+	req.Header.Set("User-Agent", userAgent)
+	return send(&p.HttpClient, req, sub.Service)
+}
@@ -0,0 +1,297 @@
+package reminder_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+type push struct {
+	Header http.Header
+	Body   string
+}
+
+func pushServer(t *testing.T) (*httptest.Server, chan push) {
+	pushes := make(chan push, 10)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pushes <- push{r.Header, string(body)}
+	}))
+	t.Cleanup(svr.Close)
+	return svr, pushes
+}
+
+func pushStore(subs ...reminder.PushSubscription) *reminder.Store[reminder.PushSubscription] {
+	store, _ := reminder.NewStore[reminder.PushSubscription]("")
+	for i, sub := range subs {
+		sub.Id = string(rune('a' + i))
+		sub.PropertyId = PropertyId
+		store.Put(sub.Id, sub)
+	}
+	return store
+}
+
+var SixPm = reminder.TimeOfDay{Hour: 18}
+
+func TestPushToNtfy(t *testing.T) {
+	svr, pushes := pushServer(t)
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	notifier := reminder.PushNotifier{
+		Store:  pushStore(reminder.PushSubscription{Service: reminder.Ntfy, Url: svr.URL, Token: "tk_abc", Time: SixPm}),
+		Lookup: lookup,
+	}
+
+	notifier.Send(context.Background(), Evening)
+
+	p := <-pushes
+	assert.Equal(t, "Put out: recycling, food", p.Body)
+	assert.Equal(t, "Bins tomorrow", p.Header.Get("Title"))
+	assert.Equal(t, "Bearer tk_abc", p.Header.Get("Authorization"))
+}
+
+func TestPushToNtfyWithoutToken(t *testing.T) {
+	svr, pushes := pushServer(t)
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	notifier := reminder.PushNotifier{
+		Store:  pushStore(reminder.PushSubscription{Service: reminder.Ntfy, Url: svr.URL, Time: SixPm}),
+		Lookup: lookup,
+	}
+
+	notifier.Send(context.Background(), Evening)
+
+	assert.Empty(t, (<-pushes).Header.Get("Authorization"))
+}
+
+func TestPushToGotify(t *testing.T) {
+	svr, pushes := pushServer(t)
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	notifier := reminder.PushNotifier{
+		Store:  pushStore(reminder.PushSubscription{Service: reminder.Gotify, Url: svr.URL, Token: "app-token", Time: SixPm}),
+		Lookup: lookup,
+	}
+
+	notifier.Send(context.Background(), Evening)
+
+	p := <-pushes
+	assert.JSONEq(t, `{"title": "Bins tomorrow", "message": "Put out: recycling, food", "priority": 5}`, p.Body)
+	assert.Equal(t, "app-token", p.Header.Get("X-Gotify-Key"))
+}
+
+func TestPushOnlyChosenTypes(t *testing.T) {
+	svr, pushes := pushServer(t)
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	notifier := reminder.PushNotifier{
+		Store: pushStore(reminder.PushSubscription{
+			Url: svr.URL, Time: SixPm, Types: []client.RefuseType{client.Food, client.Garden},
+		}),
+		Lookup: lookup,
+	}
+
+	notifier.Send(context.Background(), Evening)
+
+	assert.Equal(t, "Put out: food", (<-pushes).Body)
+}
+
+func TestNoPushWhenChosenTypesNotDue(t *testing.T) {
+	svr, pushes := pushServer(t)
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	notifier := reminder.PushNotifier{
+		Store:  pushStore(reminder.PushSubscription{Url: svr.URL, Time: SixPm, Types: []client.RefuseType{client.Garden}}),
+		Lookup: lookup,
+	}
+
+	notifier.Send(context.Background(), Evening)
+
+	assert.Empty(t, pushes)
+}
+
+func TestNoPushBeforeChosenTime(t *testing.T) {
+	svr, pushes := pushServer(t)
+	lookup, lookups := lookupReturning(DueTomorrow, true, nil)
+	notifier := reminder.PushNotifier{
+		Store:  pushStore(reminder.PushSubscription{Url: svr.URL, Time: reminder.TimeOfDay{Hour: 18, Minute: 1}}),
+		Lookup: lookup,
+	}
+
+	notifier.Send(context.Background(), Evening)
+
+	assert.Empty(t, pushes)
+	assert.Equal(t, 0, *lookups)
+}
+
+func TestPushOnlyOnceADay(t *testing.T) {
+	svr, pushes := pushServer(t)
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	notifier := reminder.PushNotifier{
+		Store:  pushStore(reminder.PushSubscription{Url: svr.URL, Time: SixPm}),
+		Lookup: lookup,
+	}
+
+	notifier.Send(context.Background(), Evening)
+	notifier.Send(context.Background(), Evening.Add(time.Minute))
+
+	assert.Len(t, pushes, 1)
+	assert.Equal(t, client.NewDate(2024, time.January, 1), notifier.Store.All()[0].LastSent)
+}
+
+func TestPushAfterLookupFails(t *testing.T) {
+	svr, pushes := pushServer(t)
+	lookups := 0
+	notifier := reminder.PushNotifier{
+		Store: pushStore(reminder.PushSubscription{Url: svr.URL, Time: SixPm}),
+		Lookup: func(ctx context.Context, propertyId string, date client.Date) (reminder.Collection, bool, error) {
+			lookups++
+			if lookups == 1 {
+				return reminder.Collection{}, false, errors.New("Council API down")
+			}
+			return DueTomorrow, true, nil
+		},
+	}
+
+	notifier.Send(context.Background(), Evening)
+	assert.Empty(t, pushes)
+	assert.True(t, notifier.Store.All()[0].LastSent.IsZero())
+	notifier.Send(context.Background(), Evening.Add(time.Minute))
+	notifier.Send(context.Background(), Evening.Add(time.Minute*2))
+
+	assert.Len(t, pushes, 1)
+	assert.Equal(t, 2, lookups)
+}
+
+// Every day has a collection, so only the clocks changing matters.
+func lookupAlwaysDue(ctx context.Context, propertyId string, date client.Date) (reminder.Collection, bool, error) {
+	return DueTomorrow, true, nil
+}
+
+func TestPushWhenClocksGoForward(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	svr, pushes := pushServer(t)
+	notifier := reminder.PushNotifier{
+		Store:  pushStore(reminder.PushSubscription{Url: svr.URL, Time: reminder.TimeOfDay{Hour: 1, Minute: 30}}),
+		Lookup: lookupAlwaysDue,
+	}
+
+	// 00:59 GMT is followed by 02:00 BST, so 01:30 never happens.
+	notifier.Send(context.Background(), time.Date(2024, 3, 31, 0, 59, 0, 0, london))
+	assert.Empty(t, pushes)
+	notifier.Send(context.Background(), time.Date(2024, 3, 31, 2, 0, 0, 0, london))
+
+	assert.Len(t, pushes, 1)
+}
+
+func TestPushOnceWhenClocksGoBack(t *testing.T) {
+	svr, pushes := pushServer(t)
+	notifier := reminder.PushNotifier{
+		Store:  pushStore(reminder.PushSubscription{Url: svr.URL, Time: reminder.TimeOfDay{Hour: 1, Minute: 30}}),
+		Lookup: lookupAlwaysDue,
+	}
+
+	// 01:30 BST, then 01:30 GMT an hour later.
+	notifier.Send(context.Background(), time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC))
+	notifier.Send(context.Background(), time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC))
+
+	assert.Len(t, pushes, 1)
+}
+
+func TestRetryFailedPush(t *testing.T) {
+	calls := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 2 {
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		}
+	}))
+	defer svr.Close()
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	notifier := reminder.PushNotifier{
+		Store:       pushStore(reminder.PushSubscription{Url: svr.URL, Time: SixPm}),
+		Lookup:      lookup,
+		MaxAttempts: 3,
+	}
+
+	notifier.Send(context.Background(), Evening)
+
+	assert.Equal(t, 2, calls)
+}
+
+func TestRunPushesAtChosenTime(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	svr, pushes := pushServer(t)
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 1, 1, 17, 58, 30, 0, london))
+	notifier := reminder.PushNotifier{
+		Store:  pushStore(reminder.PushSubscription{Url: svr.URL, Time: SixPm}),
+		Lookup: lookup,
+		Clock:  clock,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	clock.BlockUntilContext(ctx, 1)
+	clock.Advance(time.Second * 30)
+	clock.BlockUntilContext(ctx, 1)
+	assert.Empty(t, pushes)
+	clock.Advance(time.Minute)
+
+	assert.Equal(t, "Put out: recycling, food", (<-pushes).Body)
+}
+
+func TestRunIsNotHeldUpBySlowServers(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	svr, pushes := pushServer(t)
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 1, 1, 17, 59, 30, 0, london))
+	notifier := reminder.PushNotifier{
+		Store: pushStore(
+			reminder.PushSubscription{Url: slow.URL, Time: SixPm},
+			reminder.PushSubscription{Url: svr.URL, Time: reminder.TimeOfDay{Hour: 18, Minute: 1}},
+		),
+		Lookup: lookup,
+		Clock:  clock,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	clock.BlockUntilContext(ctx, 1)
+	clock.Advance(time.Second * 30)
+	clock.BlockUntilContext(ctx, 1)
+	clock.Advance(time.Minute)
+
+	assert.Equal(t, "Put out: recycling, food", (<-pushes).Body)
+}
+
+func TestTimeOfDayJson(t *testing.T) {
+	var parsed reminder.TimeOfDay
+
+	err := json.Unmarshal([]byte(`"07:05"`), &parsed)
+	text, _ := json.Marshal(parsed)
+
+	assert.Nil(t, err)
+	assert.Equal(t, reminder.TimeOfDay{Hour: 7, Minute: 5}, parsed)
+	assert.Equal(t, `"07:05"`, string(text))
+}
+
+func TestBadTimeOfDay(t *testing.T) {
+	for _, s := range []string{"", "7pm", "24:00", "18:60", "18"} {
+		_, err := reminder.ParseTimeOfDay(s)
+		assert.ErrorContains(t, err, "Not a time of day", s)
+	}
+}
@@ -0,0 +1,60 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// Calls attempt until it succeeds, fails in a way that won't be fixed by
+// trying again, or has been called maxAttempts times. Waits backoff before
+// the first retry, doubling it after each one.
+func retry(ctx context.Context, clock clockwork.Clock, maxAttempts int, backoff time.Duration, attempt func() (bool, error)) error {
+	for n := 1; ; n++ {
+		retryable, err := attempt()
+		if err == nil {
+			return nil
+		}
+		if !retryable || n >= maxAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// A response with a status code other than 2xx.
+type statusError struct {
+	statusCode int
+	calling    string
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("Status code %v calling %v", e.statusCode, e.calling)
+}
+
+// Sends a request, saying whether it is worth trying again if it fails.
+// Transport errors, server errors and rate limiting are, but not other client
+// errors, which will only happen again.
+func send(httpClient *http.Client, req *http.Request, calling string) (bool, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return !errors.Is(err, ErrPrivateAddress), err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, statusError{resp.StatusCode, calling}
+}
@@ -0,0 +1,53 @@
+package reminder
+
+import (
+	"context"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// Runs a job once a day at the same time in London, such as the evening
+// before collections.
+type Scheduler struct {
+	// Nil means the real clock.
+	Clock  clockwork.Clock
+	Hour   int
+	Minute int
+}
+
+func (s Scheduler) clock() clockwork.Clock {
+	if s.Clock == nil {
+		return clockwork.NewRealClock()
+	}
+	return s.Clock
+}
+
+// The first time the job is due after now.
+func (s Scheduler) next(now time.Time, london *time.Location) time.Time {
+	now = now.In(london)
+	next := time.Date(now.Year(), now.Month(), now.Day(), s.Hour, s.Minute, 0, 0, london)
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, s.Hour, s.Minute, 0, 0, london)
+	}
+	return next
+}
+
+// Calls job each day when it is due, until ctx is done. Days missed while
+// the service was not running are skipped, not made up.
+func (s Scheduler) Run(ctx context.Context, job func(ctx context.Context, now time.Time)) error {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		return err
+	}
+	clock := s.clock()
+	for {
+		now := clock.Now()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now = <-clock.After(s.next(now, london).Sub(now)):
+		}
+		job(ctx, now)
+	}
+}
@@ -0,0 +1,83 @@
+package reminder_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	_ "time/tzdata"
+
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func runScheduler(t *testing.T, start time.Time) (*clockwork.FakeClock, chan time.Time, context.CancelFunc) {
+	clock := clockwork.NewFakeClockAt(start)
+	runs := make(chan time.Time, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	scheduler := reminder.Scheduler{Clock: clock, Hour: 18}
+	go scheduler.Run(ctx, func(ctx context.Context, now time.Time) {
+		runs <- now
+	})
+	return clock, runs, cancel
+}
+
+func TestRunEveryEvening(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	clock, runs, _ := runScheduler(t, time.Date(2024, 1, 1, 17, 0, 0, 0, london))
+
+	clock.BlockUntilContext(context.Background(), 1)
+	clock.Advance(time.Minute * 59)
+	select {
+	case <-runs:
+		t.Fatal("ran too early")
+	default:
+	}
+	clock.Advance(time.Minute)
+	first := <-runs
+	clock.BlockUntilContext(context.Background(), 1)
+	clock.Advance(time.Hour * 24)
+	second := <-runs
+
+	assert.Equal(t, time.Date(2024, 1, 1, 18, 0, 0, 0, london), first.In(london))
+	assert.Equal(t, time.Date(2024, 1, 2, 18, 0, 0, 0, london), second.In(london))
+}
+
+func TestRunTomorrowIfTooLateToday(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	clock, runs, _ := runScheduler(t, time.Date(2024, 1, 1, 18, 0, 1, 0, london))
+
+	clock.BlockUntilContext(context.Background(), 1)
+	clock.Advance(time.Hour*24 - time.Second)
+	run := <-runs
+
+	assert.Equal(t, time.Date(2024, 1, 2, 18, 0, 0, 0, london), run.In(london))
+}
+
+func TestRunAtSameLondonTimeWhenClocksChange(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	clock, runs, _ := runScheduler(t, time.Date(2024, 3, 30, 19, 0, 0, 0, london))
+
+	clock.BlockUntilContext(context.Background(), 1)
+	// The clocks go forward overnight, so the day is an hour shorter.
+	clock.Advance(time.Hour * 22)
+	run := <-runs
+
+	assert.Equal(t, time.Date(2024, 3, 31, 18, 0, 0, 0, london), run.In(london))
+}
+
+func TestStopScheduler(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- reminder.Scheduler{Clock: clock, Hour: 18}.Run(ctx, func(ctx context.Context, now time.Time) {})
+	}()
+
+	clock.BlockUntilContext(context.Background(), 1)
+	cancel()
+
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
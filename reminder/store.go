@@ -0,0 +1,183 @@
+package reminder
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Too many subscriptions to add another.
+var ErrFull = errors.New("There are too many subscriptions to add another")
+
+// Keeps subscriptions of one kind by ID, writing them all to a JSON file
+// after every change so that they survive restarts. Safe for concurrent use.
+type Store[T any] struct {
+	path  string
+	mu    sync.Mutex
+	items map[string]T
+
+	// Most subscriptions to keep, so that nobody can fill the disk. Zero
+	// means no limit.
+	MaxItems int
+	// Most subscriptions to keep for any one property. Zero means no limit.
+	MaxPerProperty int
+	// Which property a subscription is for. Needed for MaxPerProperty.
+	PropertyOf func(T) string
+}
+
+// Loads whatever was saved to path before. It is not an error for the file
+// not to exist yet. An empty path means subscriptions are only kept in memory.
+func NewStore[T any](path string) (*Store[T], error) {
+	s := &Store[T]{path: path, items: map[string]T{}}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.items); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store[T]) Get(id string) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, found := s.items[id]
+	return item, found
+}
+
+// Every subscription, in order of ID.
+func (s *Store[T]) All() []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []T
+	for _, id := range slices.Sorted(maps.Keys(s.items)) {
+		res = append(res, s.items[id])
+	}
+	return res
+}
+
+// Adds a subscription, or replaces the one with the same ID. Gives ErrFull
+// rather than adding one beyond the limits.
+func (s *Store[T]) Put(id string, item T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, existed := s.items[id]
+	if !existed && s.full(item) {
+		return ErrFull
+	}
+	s.items[id] = item
+	if err := s.save(); err != nil {
+		if existed {
+			s.items[id] = old
+		} else {
+			delete(s.items, id)
+		}
+		return err
+	}
+	return nil
+}
+
+// Replaces each subscription with one of the IDs by what change makes of it,
+// if there still is one and change says to, saving them all at once. Gives the
+// IDs of the ones replaced. Unlike Get then Put, nothing else can change or
+// delete them in between.
+func (s *Store[T]) Update(ids []string, change func(T) (T, bool)) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := make(map[string]T)
+	var changed []string
+	for _, id := range ids {
+		item, found := s.items[id]
+		if !found {
+			continue
+		}
+		if _, seen := old[id]; seen {
+			continue
+		}
+		updated, ok := change(item)
+		if !ok {
+			continue
+		}
+		old[id] = item
+		s.items[id] = updated
+		changed = append(changed, id)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	if err := s.save(); err != nil {
+		for id, item := range old {
+			s.items[id] = item
+		}
+		return nil, err
+	}
+	return changed, nil
+}
+
+func (s *Store[T]) full(item T) bool {
+	if s.MaxItems > 0 && len(s.items) >= s.MaxItems {
+		return true
+	}
+	if s.MaxPerProperty <= 0 || s.PropertyOf == nil {
+		return false
+	}
+	property := s.PropertyOf(item)
+	count := 0
+	for _, other := range s.items {
+		if s.PropertyOf(other) == property {
+			count++
+		}
+	}
+	return count >= s.MaxPerProperty
+}
+
+// It is not an error for there to be no subscription with the ID.
+func (s *Store[T]) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, existed := s.items[id]
+	if !existed {
+		return nil
+	}
+	delete(s.items, id)
+	if err := s.save(); err != nil {
+		s.items[id] = old
+		return err
+	}
+	return nil
+}
+
+// The old copy is only replaced once the new one has been written in full.
+func (s *Store[T]) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s.items)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
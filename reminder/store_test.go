@@ -0,0 +1,136 @@
+package reminder_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	Name string
+}
+
+func TestStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	before, _ := reminder.NewStore[item](path)
+
+	before.Put("b", item{"bar"})
+	before.Put("a", item{"foo"})
+	before.Put("c", item{"baz"})
+	before.Delete("c")
+	after, err := reminder.NewStore[item](path)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []item{{"foo"}, {"bar"}}, after.All())
+	got, found := after.Get("b")
+	assert.True(t, found)
+	assert.Equal(t, item{"bar"}, got)
+}
+
+func TestStoreFileNotThereYet(t *testing.T) {
+	store, err := reminder.NewStore[item](filepath.Join(t.TempDir(), "subscriptions.json"))
+
+	assert.Nil(t, err)
+	assert.Empty(t, store.All())
+}
+
+func TestStoreFileCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	os.WriteFile(path, []byte("not json"), 0644)
+
+	_, err := reminder.NewStore[item](path)
+
+	assert.NotNil(t, err)
+}
+
+func TestStoreInMemory(t *testing.T) {
+	store, _ := reminder.NewStore[item]("")
+
+	err := store.Put("a", item{"foo"})
+	_, found := store.Get("a")
+
+	assert.Nil(t, err)
+	assert.True(t, found)
+}
+
+func TestStoreKeepsOldCopyWhenSaveFails(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := reminder.NewStore[item](filepath.Join(dir, "missing", "subscriptions.json"))
+
+	err := store.Put("a", item{"foo"})
+	_, found := store.Get("a")
+
+	assert.NotNil(t, err)
+	assert.False(t, found)
+}
+
+func TestDeleteMissingSubscription(t *testing.T) {
+	store, _ := reminder.NewStore[item](filepath.Join(t.TempDir(), "subscriptions.json"))
+
+	assert.Nil(t, store.Delete("a"))
+}
+
+func TestStoreLimit(t *testing.T) {
+	store, _ := reminder.NewStore[item]("")
+	store.MaxItems = 2
+
+	store.Put("a", item{"foo"})
+	store.Put("b", item{"bar"})
+	err := store.Put("c", item{"baz"})
+	replaced := store.Put("a", item{"qux"})
+
+	assert.ErrorIs(t, err, reminder.ErrFull)
+	assert.Nil(t, replaced)
+	assert.Equal(t, []item{{"qux"}, {"bar"}}, store.All())
+}
+
+func TestStoreLimitPerProperty(t *testing.T) {
+	store, _ := reminder.NewStore[item]("")
+	store.MaxPerProperty = 1
+	store.PropertyOf = func(i item) string { return i.Name }
+
+	store.Put("a", item{"foo"})
+	err := store.Put("b", item{"foo"})
+	other := store.Put("c", item{"bar"})
+
+	assert.ErrorIs(t, err, reminder.ErrFull)
+	assert.Nil(t, other)
+	assert.Equal(t, []item{{"foo"}, {"bar"}}, store.All())
+}
+
+func TestStoreUpdate(t *testing.T) {
+	store, _ := reminder.NewStore[item]("")
+	store.Put("a", item{"foo"})
+	store.Put("b", item{"bar"})
+	rename := func(i item) (item, bool) {
+		if i.Name == "bar" {
+			return i, false
+		}
+		return item{"bar"}, true
+	}
+
+	first, _ := store.Update([]string{"a", "b", "c"}, rename)
+	second, _ := store.Update([]string{"a"}, rename)
+
+	assert.Equal(t, []string{"a"}, first)
+	assert.Empty(t, second)
+	assert.Equal(t, []item{{"bar"}, {"bar"}}, store.All())
+}
+
+func TestStoreUpdateKeepsOldCopiesWhenSaveFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	store, _ := reminder.NewStore[item](path)
+	store.Put("a", item{"foo"})
+	store.Put("b", item{"bar"})
+	os.Remove(path)
+	os.Mkdir(path, 0o755)
+
+	changed, err := store.Update([]string{"a", "b"}, func(i item) (item, bool) { return item{"baz"}, true })
+
+	assert.NotNil(t, err)
+	assert.Empty(t, changed)
+	assert.Equal(t, []item{{"foo"}, {"bar"}}, store.All())
+}
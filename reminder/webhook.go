@@ -0,0 +1,117 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+const userAgent = "github.com/dinosaursrarr/hackney-bindicator"
+
+// Asks for a URL to be called the evening before each collection at a property.
+type Webhook struct {
+	Id         string
+	PropertyId string
+	Url        string
+	// Shared with the subscriber, who can use it to check payloads came from
+	// us, and to unsubscribe.
+	Secret  string
+	Created time.Time
+}
+
+// Sent to a webhook the evening before a collection.
+type WebhookPayload struct {
+	SubscriptionId string
+	Collection
+}
+
+// Gives the signature sent in the X-Bindicator-Signature header, so that
+// subscribers can check a payload came from us and hasn't been changed.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Calls every webhook whose property has a collection tomorrow.
+type WebhookSender struct {
+	Store      *Store[Webhook]
+	Lookup     Lookup
+	HttpClient http.Client
+	// Nil means the real clock.
+	Clock clockwork.Clock
+	// Most attempts at each delivery, including the first. Zero means one.
+	MaxAttempts int
+	// How long to wait before the first retry. Doubles after each one.
+	Backoff time.Duration
+}
+
+func (s *WebhookSender) clock() clockwork.Clock {
+	if s.Clock == nil {
+		return clockwork.NewRealClock()
+	}
+	return s.Clock
+}
+
+// Meant to be run by a Scheduler in the evening. Waits for every delivery to
+// succeed or give up.
+func (s *WebhookSender) Send(ctx context.Context, now time.Time) {
+	date, err := tomorrow(now)
+	if err != nil {
+		log.Println("could not send webhooks:", err)
+		return
+	}
+	lookup := memoize(s.Lookup)
+	var wg sync.WaitGroup
+	for _, hook := range s.Store.All() {
+		collection, found, err := lookup(ctx, hook.PropertyId, date)
+		if err != nil {
+			log.Println("could not look up collections for webhook", hook.Id, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		body, err := json.Marshal(WebhookPayload{SubscriptionId: hook.Id, Collection: collection})
+		if err != nil {
+			log.Println("could not build webhook payload", hook.Id, err)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.deliver(ctx, hook, body); err != nil {
+				log.Println("could not deliver webhook", hook.Id, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *WebhookSender) deliver(ctx context.Context, hook Webhook, body []byte) error {
+	deliveryId := NewToken(16)
+	return retry(ctx, s.clock(), s.MaxAttempts, s.Backoff, func() (bool, error) {
+		return s.post(ctx, hook, deliveryId, body)
+	})
+}
+
+func (s *WebhookSender) post(ctx context.Context, hook Webhook, deliveryId string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Bindicator-Delivery", deliveryId)
+	req.Header.Set("X-Bindicator-Signature", Sign(hook.Secret, body))
+	return send(&s.HttpClient, req, "webhook")
+}
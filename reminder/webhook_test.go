@@ -0,0 +1,196 @@
+package reminder_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dinosaursrarr/hackney-bindicator/client"
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+	"github.com/stretchr/testify/assert"
+)
+
+const PropertyId = "property"
+
+// Evening before 2024-01-02
+var Evening = time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)
+
+var Tomorrow = client.NewDate(2024, time.January, 2)
+
+var DueTomorrow = reminder.Collection{
+	PropertyId: PropertyId,
+	Name:       "29 ACACIA AVENUE",
+	Date:       Tomorrow,
+	Summary:    "Recycling and food waste",
+	Types:      []string{"recycling", "food"},
+	Bins: []reminder.Bin{
+		{Id: "bin1", Name: "Recycling sack", Type: "recycling"},
+		{Id: "bin2", Name: "Food caddy", Type: "food"},
+	},
+}
+
+func lookupReturning(collection reminder.Collection, found bool, err error) (reminder.Lookup, *int) {
+	var mu sync.Mutex
+	calls := 0
+	return func(ctx context.Context, propertyId string, date client.Date) (reminder.Collection, bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if date != Tomorrow {
+			return reminder.Collection{}, false, nil
+		}
+		return collection, found, err
+	}, &calls
+}
+
+func webhookStore(t *testing.T, urls ...string) *reminder.Store[reminder.Webhook] {
+	store, _ := reminder.NewStore[reminder.Webhook]("")
+	for i, url := range urls {
+		id := string(rune('a' + i))
+		store.Put(id, reminder.Webhook{Id: id, PropertyId: PropertyId, Url: url, Secret: "secret-" + id})
+	}
+	return store
+}
+
+func TestSendSignedWebhook(t *testing.T) {
+	var body []byte
+	var header http.Header
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer svr.Close()
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	sender := reminder.WebhookSender{Store: webhookStore(t, svr.URL), Lookup: lookup}
+
+	sender.Send(context.Background(), Evening)
+
+	assert.JSONEq(t, `
+		{
+			"SubscriptionId": "a",
+			"PropertyId": "property",
+			"Name": "29 ACACIA AVENUE",
+			"Date": "2024-01-02",
+			"Summary": "Recycling and food waste",
+			"Types": ["recycling", "food"],
+			"Bins": [
+				{"Id": "bin1", "Name": "Recycling sack", "Type": "recycling"},
+				{"Id": "bin2", "Name": "Food caddy", "Type": "food"}
+			]
+		}`, string(body))
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, reminder.Sign("secret-a", body), header.Get("X-Bindicator-Signature"))
+	assert.NotEmpty(t, header.Get("X-Bindicator-Delivery"))
+}
+
+func TestSignature(t *testing.T) {
+	// Worked out with: printf '{}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=77325902caca812dc259733aacd046b73817372c777b8d95b402647474516e13", reminder.Sign("secret", []byte("{}")))
+}
+
+func TestNoWebhookWhenNothingDue(t *testing.T) {
+	calls := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer svr.Close()
+	lookup, _ := lookupReturning(reminder.Collection{}, false, nil)
+	sender := reminder.WebhookSender{Store: webhookStore(t, svr.URL), Lookup: lookup}
+
+	sender.Send(context.Background(), Evening)
+
+	assert.Equal(t, 0, calls)
+}
+
+func TestNoWebhookWhenLookupFails(t *testing.T) {
+	calls := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer svr.Close()
+	lookup, _ := lookupReturning(DueTomorrow, true, errors.New("nope"))
+	sender := reminder.WebhookSender{Store: webhookStore(t, svr.URL), Lookup: lookup}
+
+	sender.Send(context.Background(), Evening)
+
+	assert.Equal(t, 0, calls)
+}
+
+func TestLookUpEachPropertyOnce(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer svr.Close()
+	lookup, lookups := lookupReturning(DueTomorrow, true, nil)
+	sender := reminder.WebhookSender{Store: webhookStore(t, svr.URL, svr.URL, svr.URL), Lookup: lookup}
+
+	sender.Send(context.Background(), Evening)
+
+	assert.Equal(t, 1, *lookups)
+}
+
+func TestRetryFailedWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var deliveries []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		deliveries = append(deliveries, r.Header.Get("X-Bindicator-Delivery"))
+		if len(deliveries) < 3 {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+	}))
+	defer svr.Close()
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	sender := reminder.WebhookSender{Store: webhookStore(t, svr.URL), Lookup: lookup, MaxAttempts: 5}
+
+	sender.Send(context.Background(), Evening)
+
+	assert.Len(t, deliveries, 3)
+	// So that the subscriber can tell they are the same reminder.
+	assert.Equal(t, deliveries[0], deliveries[2])
+}
+
+func TestGiveUpOnWebhookAfterMaxAttempts(t *testing.T) {
+	calls := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer svr.Close()
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	sender := reminder.WebhookSender{Store: webhookStore(t, svr.URL), Lookup: lookup, MaxAttempts: 3}
+
+	sender.Send(context.Background(), Evening)
+
+	assert.Equal(t, 3, calls)
+}
+
+func TestDontRetryRejectedWebhook(t *testing.T) {
+	calls := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer svr.Close()
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+	sender := reminder.WebhookSender{Store: webhookStore(t, svr.URL), Lookup: lookup, MaxAttempts: 3}
+
+	sender.Send(context.Background(), Evening)
+
+	assert.Equal(t, 1, calls)
+}
+
+func TestWebhookPayloadDecodes(t *testing.T) {
+	body, _ := json.Marshal(reminder.WebhookPayload{SubscriptionId: "a", Collection: DueTomorrow})
+	var payload reminder.WebhookPayload
+
+	err := json.Unmarshal(body, &payload)
+
+	assert.Nil(t, err)
+	assert.Equal(t, DueTomorrow, payload.Collection)
+}
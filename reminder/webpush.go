@@ -0,0 +1,290 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// Identifies this server to browsers' push services (RFC 8292). Browsers
+// only accept notifications signed with the key they subscribed with, so it
+// must stay the same.
+type VAPID struct {
+	Key *ecdsa.PrivateKey
+	// How the push service can contact whoever runs this server, e.g.
+	// mailto:bins@example.com.
+	Subject string
+}
+
+// Reads a private key written as the base64url encoding of its 32 bytes, as
+// made by most Web Push tools.
+func ParseVAPIDKey(s string) (*ecdsa.PrivateKey, error) {
+	b, err := decodeKey(s)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read VAPID key: %w", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), b)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read VAPID key: %w", err)
+	}
+	return key, nil
+}
+
+// What browsers need as the applicationServerKey to subscribe.
+func (v VAPID) PublicKey() string {
+	b, _ := v.Key.PublicKey.Bytes()
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// A JWT saying who is sending, which push services require.
+func (v VAPID) authorization(endpoint string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header, _ := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	claims, _ := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(time.Hour * 12).Unix(),
+		"sub": v.Subject,
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, v.Key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	jwt := unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)
+	return "vapid t=" + jwt + ", k=" + v.PublicKey(), nil
+}
+
+// A browser that wants a notification the evening before each collection at
+// a property. The fields other than PropertyId come from the browser's
+// PushSubscription.
+type WebPushSubscription struct {
+	Id         string
+	PropertyId string
+	// Where to send notifications. Only the browser and its push service
+	// know it, so it also serves as proof of who is asking to unsubscribe.
+	Endpoint string
+	// The browser's public key, and the secret shared with it, for
+	// encrypting notifications.
+	P256dh  string
+	Auth    string
+	Created time.Time
+}
+
+// The same browser subscribing to the same property again replaces its old
+// subscription, rather than getting two notifications.
+func WebPushSubscriptionId(endpoint, propertyId string) string {
+	sum := sha256.Sum256([]byte(propertyId + " " + endpoint))
+	return hex.EncodeToString(sum[:16])
+}
+
+func decodeKey(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// Checks the keys are ones notifications can be encrypted with.
+func (s WebPushSubscription) CheckKeys() error {
+	p256dh, err := decodeKey(s.P256dh)
+	if err != nil {
+		return errors.New("p256dh must be base64url encoded")
+	}
+	if _, err := ecdh.P256().NewPublicKey(p256dh); err != nil {
+		return errors.New("p256dh must be a P-256 public key")
+	}
+	auth, err := decodeKey(s.Auth)
+	if err != nil || len(auth) != 16 {
+		return errors.New("auth must be 16 bytes, base64url encoded")
+	}
+	return nil
+}
+
+// Encrypts a notification so that only the browser can read it, as described
+// in RFC 8291.
+func (s WebPushSubscription) encrypt(plaintext []byte) ([]byte, error) {
+	uaPublicBytes, err := decodeKey(s.P256dh)
+	if err != nil {
+		return nil, err
+	}
+	authSecret, err := decodeKey(s.Auth)
+	if err != nil {
+		return nil, err
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	rand.Read(salt)
+
+	keyInfo := "WebPush: info\x00" + string(uaPublicBytes) + string(asPublic)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// One record, so the padding delimiter is the one for the last record.
+	const recordSize = 4096
+	var res bytes.Buffer
+	res.Write(salt)
+	binary.Write(&res, binary.BigEndian, uint32(recordSize))
+	res.WriteByte(byte(len(asPublic)))
+	res.Write(asPublic)
+	record := append(append([]byte{}, plaintext...), 2)
+	if res.Len()+len(record)+gcm.Overhead() > recordSize {
+		return nil, errors.New("Notification is too long")
+	}
+	res.Write(gcm.Seal(nil, nonce, record, nil))
+	return res.Bytes(), nil
+}
+
+// What the service worker is sent, to show as a notification.
+type WebPushPayload struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// The page to open when the notification is clicked.
+	Url string `json:"url"`
+}
+
+// Sends a notification to every browser whose property has a collection
+// tomorrow.
+type WebPushSender struct {
+	Store      *Store[WebPushSubscription]
+	Lookup     Lookup
+	HttpClient http.Client
+	VAPID      VAPID
+	// Nil means the real clock.
+	Clock clockwork.Clock
+	// Most attempts at each notification, including the first. Zero means one.
+	MaxAttempts int
+	// How long to wait before the first retry. Doubles after each one.
+	Backoff time.Duration
+}
+
+func (s *WebPushSender) clock() clockwork.Clock {
+	if s.Clock == nil {
+		return clockwork.NewRealClock()
+	}
+	return s.Clock
+}
+
+// Meant to be run by a Scheduler in the evening. Forgets subscriptions the
+// push service says have expired, which happens when people turn off
+// notifications or clear their browser's data.
+func (s *WebPushSender) Send(ctx context.Context, now time.Time) {
+	date, err := tomorrow(now)
+	if err != nil {
+		log.Println("could not send web push notifications:", err)
+		return
+	}
+	lookup := memoize(s.Lookup)
+	var wg sync.WaitGroup
+	for _, sub := range s.Store.All() {
+		collection, found, err := lookup(ctx, sub.PropertyId, date)
+		if err != nil {
+			log.Println("could not look up collections for web push", sub.Id, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		payload, _ := json.Marshal(WebPushPayload{
+			Title: "Bins tomorrow",
+			Body:  "Put out: " + strings.Join(collection.Types, ", "),
+			Url:   "/?" + url.Values{"property": {sub.PropertyId}}.Encode(),
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := retry(ctx, s.clock(), s.MaxAttempts, s.Backoff, func() (bool, error) {
+				return s.push(ctx, sub, payload)
+			})
+			var status statusError
+			if errors.As(err, &status) && (status.statusCode == http.StatusNotFound || status.statusCode == http.StatusGone) {
+				err = s.Store.Delete(sub.Id)
+			}
+			if err != nil {
+				log.Println("could not send web push notification", sub.Id, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *WebPushSender) push(ctx context.Context, sub WebPushSubscription, payload []byte) (bool, error) {
+	body, err := sub.encrypt(payload)
+	if err != nil {
+		return false, err
+	}
+	auth, err := s.VAPID.authorization(sub.Endpoint, s.clock().Now())
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	// Not worth showing once the bins have been collected.
+	req.Header.Set("TTL", "43200")
+	req.Header.Set("Urgency", "normal")
+	req.Header.Set("User-Agent", userAgent)
+	return send(&s.HttpClient, req, "push service")
+}
@@ -0,0 +1,241 @@
+package reminder_test
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dinosaursrarr/hackney-bindicator/reminder"
+	"github.com/stretchr/testify/assert"
+)
+
+// The application server's key from the example in RFC 8291.
+const VAPIDKey = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+
+func b64(s string) []byte {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Decrypts a notification the way a browser would (RFC 8291).
+func decrypt(uaPrivate *ecdh.PrivateKey, authSecret []byte, body []byte) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("too short")
+	}
+	salt := body[:16]
+	idLen := int(body[20])
+	asPublicBytes := body[21 : 21+idLen]
+	ciphertext := body[21+idLen:]
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := uaPrivate.ECDH(asPublic)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := "WebPush: info\x00" + string(uaPrivate.PublicKey().Bytes()) + string(asPublicBytes)
+	ikm, _ := hkdf.Key(sha256.New, shared, authSecret, keyInfo, 32)
+	cek, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	record, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+	end := strings.LastIndexByte(string(record), 2)
+	if end < 0 {
+		return nil, errors.New("no padding delimiter")
+	}
+	return record[:end], nil
+}
+
+// Checks decrypt against the example in RFC 8291, so that it can be trusted
+// to check the sender.
+func TestDecryptRfcExample(t *testing.T) {
+	uaPrivate, _ := ecdh.P256().NewPrivateKey(b64("q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"))
+	body := b64("DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN")
+
+	plaintext, err := decrypt(uaPrivate, b64("BTBZMqHH6r4Tts7J_aSIgg"), body)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "When I grow up, I want to be a watermelon", string(plaintext))
+}
+
+type browser struct {
+	private *ecdh.PrivateKey
+	auth    []byte
+}
+
+func newBrowser() browser {
+	private, _ := ecdh.P256().GenerateKey(rand.Reader)
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return browser{private, auth}
+}
+
+func (b browser) subscription(id, endpoint string) reminder.WebPushSubscription {
+	return reminder.WebPushSubscription{
+		Id:         id,
+		PropertyId: PropertyId,
+		Endpoint:   endpoint,
+		P256dh:     base64.RawURLEncoding.EncodeToString(b.private.PublicKey().Bytes()),
+		Auth:       base64.RawURLEncoding.EncodeToString(b.auth),
+	}
+}
+
+// Checks the JWT in a VAPID Authorization header was signed by its key, and
+// returns its claims.
+func verifyVAPID(t *testing.T, header string) map[string]any {
+	jwt, key, ok := strings.Cut(strings.TrimPrefix(header, "vapid t="), ", k=")
+	if !ok {
+		t.Fatal("bad Authorization header", header)
+	}
+	public, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), b64(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(jwt, ".")
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	sig := b64(parts[2])
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	assert.True(t, ecdsa.Verify(public, digest[:], r, s))
+	assert.JSONEq(t, `{"typ": "JWT", "alg": "ES256"}`, string(b64(parts[0])))
+	var claims map[string]any
+	json.Unmarshal(b64(parts[1]), &claims)
+	return claims
+}
+
+func webPushSender(t *testing.T, store *reminder.Store[reminder.WebPushSubscription], lookup reminder.Lookup) *reminder.WebPushSender {
+	key, err := reminder.ParseVAPIDKey(VAPIDKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &reminder.WebPushSender{
+		Store:  store,
+		Lookup: lookup,
+		VAPID:  reminder.VAPID{Key: key, Subject: "mailto:bins@example.com"},
+	}
+}
+
+func TestSendWebPush(t *testing.T) {
+	var header http.Header
+	var body []byte
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer svr.Close()
+	b := newBrowser()
+	store, _ := reminder.NewStore[reminder.WebPushSubscription]("")
+	store.Put("a", b.subscription("a", svr.URL+"/push/abc"))
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+
+	webPushSender(t, store, lookup).Send(context.Background(), Evening)
+
+	plaintext, err := decrypt(b.private, b.auth, body)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `
+		{
+			"title": "Bins tomorrow",
+			"body": "Put out: recycling, food",
+			"url": "/?property=property"
+		}`, string(plaintext))
+	assert.Equal(t, "aes128gcm", header.Get("Content-Encoding"))
+	assert.NotEmpty(t, header.Get("TTL"))
+	claims := verifyVAPID(t, header.Get("Authorization"))
+	assert.Equal(t, svr.URL, claims["aud"])
+	assert.Equal(t, "mailto:bins@example.com", claims["sub"])
+	assert.Greater(t, claims["exp"], float64(Evening.Unix()))
+	assert.Len(t, store.All(), 1)
+}
+
+func TestNoWebPushWhenNothingDue(t *testing.T) {
+	calls := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer svr.Close()
+	store, _ := reminder.NewStore[reminder.WebPushSubscription]("")
+	store.Put("a", newBrowser().subscription("a", svr.URL))
+	lookup, _ := lookupReturning(reminder.Collection{}, false, nil)
+
+	webPushSender(t, store, lookup).Send(context.Background(), Evening)
+
+	assert.Equal(t, 0, calls)
+}
+
+func TestForgetExpiredWebPushSubscriptions(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/gone") {
+			http.Error(w, "gone", http.StatusGone)
+		}
+	}))
+	defer svr.Close()
+	store, _ := reminder.NewStore[reminder.WebPushSubscription]("")
+	store.Put("a", newBrowser().subscription("a", svr.URL+"/gone"))
+	store.Put("b", newBrowser().subscription("b", svr.URL+"/ok"))
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+
+	webPushSender(t, store, lookup).Send(context.Background(), Evening)
+
+	subs := store.All()
+	assert.Len(t, subs, 1)
+	assert.Equal(t, "b", subs[0].Id)
+}
+
+func TestKeepWebPushSubscriptionsAfterOtherErrors(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer svr.Close()
+	store, _ := reminder.NewStore[reminder.WebPushSubscription]("")
+	store.Put("a", newBrowser().subscription("a", svr.URL))
+	lookup, _ := lookupReturning(DueTomorrow, true, nil)
+
+	webPushSender(t, store, lookup).Send(context.Background(), Evening)
+
+	assert.Len(t, store.All(), 1)
+}
+
+func TestVAPIDPublicKey(t *testing.T) {
+	key, _ := reminder.ParseVAPIDKey(VAPIDKey)
+
+	assert.Equal(t, "BP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A8", reminder.VAPID{Key: key}.PublicKey())
+}
+
+func TestBadVAPIDKey(t *testing.T) {
+	for _, s := range []string{"", "not base64!", "c2hvcnQ"} {
+		_, err := reminder.ParseVAPIDKey(s)
+		assert.ErrorContains(t, err, "Could not read VAPID key", s)
+	}
+}
+
+func TestWebPushSubscriptionId(t *testing.T) {
+	a := reminder.WebPushSubscriptionId("https://push.example.com/a", "property1")
+
+	assert.Len(t, a, 32)
+	assert.Equal(t, a, reminder.WebPushSubscriptionId("https://push.example.com/a", "property1"))
+	assert.NotEqual(t, a, reminder.WebPushSubscriptionId("https://push.example.com/b", "property1"))
+	assert.NotEqual(t, a, reminder.WebPushSubscriptionId("https://push.example.com/a", "property2"))
+}
@@ -0,0 +1,16 @@
+// Shows the reminders sent the evening before collections, and opens the
+// property's page when one is clicked.
+
+self.addEventListener('push', (event) => {
+    const data = event.data ? event.data.json() : {};
+    event.waitUntil(self.registration.showNotification(data.title || 'Bins tomorrow', {
+        body: data.body,
+        data: { url: data.url || '/' },
+        tag: 'bindicator'
+    }));
+});
+
+self.addEventListener('notificationclick', (event) => {
+    event.notification.close();
+    event.waitUntil(self.clients.openWindow(event.notification.data.url));
+});